@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// the body assertions only look at the start of the body
+	maxBodySize = 1 << 20
+	// the rest of the body is read and thrown away so the connection can be reused,
+	// bodies larger than this close the connection instead
+	maxDrainSize = 4 << 20
+)
+
+// state is how a target is doing after a check
+type state int
+
+const (
+	down state = iota
+	// degraded targets answer, but too slowly or with a status that isn't a server error
+	degraded
+	up
+)
+
+func (s state) String() string {
+	switch s {
+	case up:
+		return "up"
+	case degraded:
+		return "degraded"
+	}
+	return "down"
+}
+
+// checkResult is what a single check found out about a target
+type checkResult struct {
+	target  string
+	url     string
+	state   state
+	code    int
+	latency time.Duration
+	err     error
+	at      time.Time
+}
+
+func (r checkResult) String() string {
+	latency := r.latency.Round(time.Millisecond)
+	switch r.state {
+	case up:
+		return fmt.Sprintf("%v is up! (%d in %v)", r.target, r.code, latency)
+	case degraded:
+		return fmt.Sprintf("%v is degraded! (%d in %v: %v)", r.target, r.code, latency, r.err)
+	}
+	return fmt.Sprintf("%v might be down! (%v)", r.target, r.err)
+}
+
+// clientConfig holds the timeouts shared by every check,
+// the timeout of each target also limits the whole check
+type clientConfig struct {
+	// time to open the tcp connection
+	ConnectTimeout time.Duration `yaml:"connect_timeout" json:"connect_timeout"`
+	// time for the tls handshake after the connection is open
+	TLSTimeout time.Duration `yaml:"tls_timeout" json:"tls_timeout"`
+	// time for the whole request, including reading the body
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+}
+
+const (
+	defaultConnectTimeout = 5 * time.Second
+	defaultTLSTimeout     = 5 * time.Second
+	defaultClientTimeout  = 30 * time.Second
+)
+
+func (c *clientConfig) setDefaults() {
+	if c.ConnectTimeout == 0 {
+		c.ConnectTimeout = defaultConnectTimeout
+	}
+	if c.TLSTimeout == 0 {
+		c.TLSTimeout = defaultTLSTimeout
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultClientTimeout
+	}
+}
+
+// newClient never uses http.DefaultClient, it has no timeouts
+// and a server that never answers would block a check forever
+func newClient(c clientConfig) *http.Client {
+	dialer := &net.Dialer{Timeout: c.ConnectTimeout, KeepAlive: 30 * time.Second}
+	return &http.Client{
+		Timeout: c.Timeout,
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   c.TLSTimeout,
+			MaxIdleConnsPerHost:   2,
+			IdleConnTimeout:       90 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+}
+
+// checkLink requests the target once with the http probe
+func checkLink(client *http.Client, t target) checkResult {
+	return check(&httpProbe{client: client}, t)
+}
+
+// httpProbe requests http and https targets with their method, headers and body
+type httpProbe struct {
+	client *http.Client
+}
+
+func (p *httpProbe) Probe(ctx context.Context, t target) checkResult {
+	r := checkResult{}
+	start := time.Now()
+
+	var body io.Reader
+	if t.RequestBody != "" {
+		body = strings.NewReader(t.RequestBody)
+	}
+	req, err := http.NewRequestWithContext(ctx, t.Method, t.URL, body)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	for k, v := range t.Headers {
+		req.Header.Set(k, v)
+	}
+	// the Host header is not sent from the header map
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	// the body is always closed, and drained first so the connection goes back to the pool
+	defer func() {
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxDrainSize))
+		resp.Body.Close()
+	}()
+
+	r.code = resp.StatusCode
+	bs, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	r.latency = time.Since(start)
+	if err != nil {
+		r.err = err
+		return r
+	}
+
+	r.state, r.err = t.classify(resp.StatusCode, string(bs), r.latency)
+	return r
+}
+
+// classify decides the state from the response, server errors and bodies that
+// fail the assertions are down, other unexpected statuses and slow answers are degraded,
+// a server error listed in expect is not an error
+func (t target) classify(code int, body string, latency time.Duration) (state, error) {
+	if code >= 500 && !t.expects(code) {
+		return down, fmt.Errorf("server error %d", code)
+	}
+	if err := t.matches(body); err != nil {
+		return down, err
+	}
+	if !t.expects(code) {
+		return degraded, fmt.Errorf("unexpected status %d", code)
+	}
+	return t.timely(latency)
+}
+
+// matches checks the body assertions, the probes without a body check what they got back instead
+func (t target) matches(body string) error {
+	if t.Body != "" && !strings.Contains(body, t.Body) {
+		return fmt.Errorf("body does not contain %q", t.Body)
+	}
+	if t.bodyRegex != nil && !t.bodyRegex.MatchString(body) {
+		return fmt.Errorf("body does not match %q", t.BodyRegex)
+	}
+	return nil
+}
+
+// timely is up when the check was faster than the slow limit of the target
+func (t target) timely(latency time.Duration) (state, error) {
+	if t.Slow > 0 && latency > t.Slow {
+		return degraded, fmt.Errorf("slower than %v", t.Slow)
+	}
+	return up, nil
+}
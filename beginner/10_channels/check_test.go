@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testTarget(url string) target {
+	c := config{Targets: []target{{URL: url}}}
+	c.setDefaults()
+	return c.Targets[0]
+}
+
+func testClient() *http.Client {
+	c := clientConfig{ConnectTimeout: 200 * time.Millisecond, TLSTimeout: 200 * time.Millisecond, Timeout: time.Second}
+	return newClient(c)
+}
+
+func TestCheckLink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "hello version 42")
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		t    target
+		up   bool
+	}{
+		{"ok", target{URL: server.URL}, true},
+		{"missing", target{URL: server.URL + "/missing"}, false},
+		{"expected missing", target{URL: server.URL + "/missing", Expect: []int{404}}, true},
+		{"body", target{URL: server.URL, Body: "hello"}, true},
+		{"wrong body", target{URL: server.URL, Body: "goodbye"}, false},
+		{"regex", target{URL: server.URL, bodyRegex: regexp.MustCompile(`version \d+`)}, true},
+		{"wrong regex", target{URL: server.URL, bodyRegex: regexp.MustCompile(`^version`)}, false},
+	}
+
+	for _, tt := range tests {
+		c := config{Targets: []target{tt.t}}
+		c.setDefaults()
+
+		r := checkLink(testClient(), c.Targets[0])
+		if (r.state == up) != tt.up {
+			t.Errorf("Expected up to be %v for %v, but got %v", tt.up, tt.name, r)
+		}
+	}
+}
+
+func TestCheckLinkTimeout(t *testing.T) {
+	release := make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	r := checkLink(testClient(), target{URL: server.URL, Method: "GET", Timeout: 50 * time.Millisecond})
+	if r.state != down || r.err == nil {
+		t.Errorf("Expected the check to time out, but got %v", r)
+	}
+}
+
+func TestCheckLinkClassifiesServerErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/500":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/503":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/429":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/slow":
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	slow := testTarget(server.URL + "/slow")
+	slow.Slow = 10 * time.Millisecond
+	maintenance := testTarget(server.URL + "/503")
+	maintenance.Expect = []int{503}
+
+	tests := []struct {
+		name string
+		t    target
+		want state
+	}{
+		{"ok", testTarget(server.URL), up},
+		{"500", testTarget(server.URL + "/500"), down},
+		{"503", testTarget(server.URL + "/503"), down},
+		{"expected 503", maintenance, up},
+		{"429", testTarget(server.URL + "/429"), degraded},
+		{"slow", slow, degraded},
+	}
+
+	for _, tt := range tests {
+		if r := checkLink(testClient(), tt.t); r.state != tt.want {
+			t.Errorf("Expected %v for %v, but got %v", tt.want, tt.name, r)
+		}
+	}
+}
+
+func TestCheckLinkHangingServer(t *testing.T) {
+	release := make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	// the client timeout stops the check even when the target allows more
+	tt := testTarget(server.URL)
+	start := time.Now()
+	r := checkLink(testClient(), tt)
+	if r.state != down || r.err == nil {
+		t.Errorf("Expected the check to time out, but got %v", r)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Expected the check to stop after the client timeout, but it took %v", elapsed)
+	}
+}
+
+func TestCheckLinkHangingTLSHandshake(t *testing.T) {
+	// the listener accepts connections but never answers the handshake
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			// the hello is read and never answered, the connection is closed when the client gives up
+			go func(conn net.Conn) {
+				defer conn.Close()
+				io.Copy(ioutil.Discard, conn)
+			}(conn)
+		}
+	}()
+
+	start := time.Now()
+	r := checkLink(testClient(), testTarget("https://"+l.Addr().String()))
+	if r.state != down || !strings.Contains(r.err.Error(), "handshake timeout") {
+		t.Errorf("Expected a tls handshake timeout, but got %v", r)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the check to stop after the tls timeout, but it took %v", elapsed)
+	}
+}
+
+func TestCheckLinkConnectionReset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		// a zero linger makes close send a reset instead of a normal close
+		conn.(*net.TCPConn).SetLinger(0)
+		conn.Close()
+	}))
+	defer server.Close()
+
+	r := checkLink(testClient(), testTarget(server.URL))
+	if r.state != down || r.err == nil {
+		t.Errorf("Expected a reset connection to be down, but got %v", r)
+	}
+}
+
+func TestCheckLinkConnectionRefused(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	if r := checkLink(testClient(), testTarget(url)); r.state != down || r.err == nil {
+		t.Errorf("Expected a closed port to be down, but got %v", r)
+	}
+}
+
+func TestCheckLinkReusesConnections(t *testing.T) {
+	var conns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a body larger than what the assertions read, it must be drained for the connection to be reused
+		fmt.Fprint(w, strings.Repeat("x", maxBodySize+1024))
+	}))
+	server.Config.ConnState = func(c net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client := testClient()
+	for i := 0; i < 5; i++ {
+		if r := checkLink(client, testTarget(server.URL)); r.state != up {
+			t.Fatalf("Expected the server to be up, but got %v", r)
+		}
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Errorf("Expected a single connection for every check, but got %v", n)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// the links checked when no config is given, every 5 seconds like the original loop
+var defaultLinks = []string{
+	"https://google.com",
+	"https://facebook.com",
+	"https://stackoverflow.com",
+	"https://golang.org",
+	"https://amazon.com",
+}
+
+const (
+	defaultInterval = 5 * time.Second
+	defaultTimeout  = 10 * time.Second
+)
+
+// config is read from a YAML or JSON file, JSON is valid YAML so both go
+// through the same decoder, durations are strings like "30s" or "1m"
+//
+//	targets:
+//	  - name: golang
+//	    url: https://golang.org
+//	    interval: 30s
+//	    timeout: 5s
+//	    method: HEAD
+//	    expect: [200, 301]
+//	    body: Go
+//	    body_regex: "v[0-9]+"
+//	    slow: 2s
+//	    notify: [ops]
+//	  - url: tcp://db.example.com:5432
+//	  - url: dns://example.com
+//	    resolver: 1.1.1.1
+//	  - url: tls://example.com
+//	    expiry_warning: 720h
+//	  - url: port://mail.example.com
+//	    ports: [25, 465, 587]
+//	client:
+//	  connect_timeout: 5s
+//	  tls_timeout: 5s
+//	  timeout: 30s
+//	dedup: 10m
+//	notifiers:
+//	  - name: ops
+//	    type: webhook
+//	    url: https://hooks.example.com/monitor
+//
+// the notifiers are described in notify.go
+type config struct {
+	Targets []target     `yaml:"targets" json:"targets"`
+	Client  clientConfig `yaml:"client" json:"client"`
+	// an alert that repeats the last one sent for a target is dropped in this window
+	Dedup     time.Duration    `yaml:"dedup" json:"dedup"`
+	Notifiers []notifierConfig `yaml:"notifiers" json:"notifiers"`
+}
+
+// target is a single site to check, the scheme of the url picks the probe,
+// http and https are requested, tcp, dns, tls and port are described in probe.go
+type target struct {
+	// name defaults to the url
+	Name     string        `yaml:"name" json:"name"`
+	URL      string        `yaml:"url" json:"url"`
+	Interval time.Duration `yaml:"interval" json:"interval"`
+	Timeout  time.Duration `yaml:"timeout" json:"timeout"`
+	// method defaults to GET, it is only used by the http probe like
+	// the expected status codes and the slow limit
+	Method string `yaml:"method" json:"method"`
+	// expect lists the status codes that mean the site is up,
+	// any 2xx or 3xx when empty
+	Expect []int `yaml:"expect" json:"expect"`
+	// the body must contain Body and match BodyRegex when they are set
+	Body      string `yaml:"body" json:"body"`
+	BodyRegex string `yaml:"body_regex" json:"body_regex"`
+	// answers slower than Slow are degraded, never when it is 0
+	Slow time.Duration `yaml:"slow" json:"slow"`
+	// headers and body sent by the http probe
+	Headers     map[string]string `yaml:"headers" json:"headers"`
+	RequestBody string            `yaml:"request_body" json:"request_body"`
+	// ports checked by port:// targets, with the port of the url if it has one
+	Ports []int `yaml:"ports" json:"ports"`
+	// the dns server used by dns:// targets, host or host:port, the system one when empty
+	Resolver string `yaml:"resolver" json:"resolver"`
+	// tls:// targets are degraded when the certificate expires sooner than this, 14 days by default
+	ExpiryWarning time.Duration `yaml:"expiry_warning" json:"expiry_warning"`
+	// names of the notifiers that get the alerts of this target, all of them when empty
+	Notify []string `yaml:"notify" json:"notify"`
+
+	bodyRegex *regexp.Regexp
+}
+
+func defaultConfig() config {
+	c := config{}
+	for _, link := range defaultLinks {
+		c.Targets = append(c.Targets, target{URL: link})
+	}
+	c.setDefaults()
+	return c
+}
+
+func loadConfig(filename string) (config, error) {
+	bs, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return config{}, err
+	}
+	return parseConfig(bs)
+}
+
+func parseConfig(bs []byte) (config, error) {
+	c := config{}
+	if err := yaml.Unmarshal(bs, &c); err != nil {
+		return config{}, err
+	}
+	if len(c.Targets) == 0 {
+		return config{}, fmt.Errorf("config has no targets")
+	}
+
+	c.setDefaults()
+	if err := c.validate(); err != nil {
+		return config{}, err
+	}
+	return c, nil
+}
+
+func (c *config) setDefaults() {
+	c.Client.setDefaults()
+	for i := range c.Targets {
+		t := &c.Targets[i]
+		if t.Name == "" {
+			t.Name = t.URL
+		}
+		if t.Interval == 0 {
+			t.Interval = defaultInterval
+		}
+		if t.Timeout == 0 {
+			t.Timeout = defaultTimeout
+		}
+		if t.Method == "" {
+			t.Method = http.MethodGet
+		}
+		t.Method = strings.ToUpper(t.Method)
+	}
+}
+
+// validate checks every target and compiles the body regexes
+func (c *config) validate() error {
+	if c.Client.ConnectTimeout < 0 || c.Client.TLSTimeout < 0 || c.Client.Timeout < 0 {
+		return fmt.Errorf("client timeouts must be positive")
+	}
+
+	names := map[string]bool{}
+	for i := range c.Targets {
+		t := &c.Targets[i]
+		if names[t.Name] {
+			return fmt.Errorf("target %q: duplicate name", t.Name)
+		}
+		names[t.Name] = true
+
+		// the client is only needed to check, not to know the probe is valid
+		if _, err := newProbe(*t, nil); err != nil {
+			return err
+		}
+		if t.Interval < 0 || t.Timeout < 0 || t.Slow < 0 {
+			return fmt.Errorf("target %q: interval, timeout and slow must be positive", t.Name)
+		}
+		for _, code := range t.Expect {
+			if code < 100 || code > 599 {
+				return fmt.Errorf("target %q: invalid status code %d", t.Name, code)
+			}
+		}
+
+		if t.BodyRegex != "" {
+			re, err := regexp.Compile(t.BodyRegex)
+			if err != nil {
+				return fmt.Errorf("target %q: %w", t.Name, err)
+			}
+			t.bodyRegex = re
+		}
+	}
+	return nil
+}
+
+// expects reports whether the status code means the target is up
+func (t target) expects(code int) bool {
+	if len(t.Expect) == 0 {
+		return code >= 200 && code < 400
+	}
+	for _, c := range t.Expect {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
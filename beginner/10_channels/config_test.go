@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseConfigYAML(t *testing.T) {
+	c, err := parseConfig([]byte(`
+targets:
+  - name: golang
+    url: https://golang.org
+    interval: 30s
+    timeout: 2s
+    method: head
+    expect: [200, 301]
+    body: Go
+    body_regex: "v[0-9]+"
+  - url: https://example.com
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.Targets) != 2 {
+		t.Fatalf("Expected 2 targets, but got %v", len(c.Targets))
+	}
+
+	g := c.Targets[0]
+	if g.Name != "golang" || g.Interval != 30*time.Second || g.Timeout != 2*time.Second || g.Method != "HEAD" {
+		t.Errorf("Expected the golang target as written, but got %+v", g)
+	}
+	if g.bodyRegex == nil || !g.expects(301) || g.expects(204) {
+		t.Errorf("Expected the regex to compile and only 200 and 301 to be expected, but got %+v", g)
+	}
+
+	e := c.Targets[1]
+	if e.Name != e.URL || e.Interval != defaultInterval || e.Timeout != defaultTimeout || e.Method != "GET" {
+		t.Errorf("Expected the defaults, but got %+v", e)
+	}
+	if !e.expects(204) || !e.expects(302) || e.expects(404) {
+		t.Errorf("Expected any 2xx or 3xx to be expected by default")
+	}
+}
+
+func TestLoadConfigJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monitor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "targets.json")
+	json := `{"targets": [{"name": "a", "url": "http://localhost:8080", "interval": "1m", "expect": [204]}]}`
+	if err := ioutil.WriteFile(filename, []byte(json), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := loadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Targets) != 1 || c.Targets[0].Interval != time.Minute || !c.Targets[0].expects(204) {
+		t.Errorf("Expected a single target checked every minute, but got %+v", c.Targets)
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := map[string]string{
+		"no targets":      `targets: []`,
+		"no url":          `targets: [{name: a}]`,
+		"bad regex":       `targets: [{url: "http://a", body_regex: "("}]`,
+		"bad status":      `targets: [{url: "http://a", expect: [42]}]`,
+		"duplicate name":  `targets: [{url: "http://a"}, {url: "http://a"}]`,
+		"bad duration":    `targets: [{url: "http://a", interval: soon}]`,
+		"negative period": `targets: [{url: "http://a", timeout: -1s}]`,
+	}
+
+	for name, yaml := range tests {
+		if _, err := parseConfig([]byte(yaml)); err == nil {
+			t.Errorf("Expected an error for %v, but got nil", name)
+		}
+	}
+}
+
+func TestDefaultConfig(t *testing.T) {
+	c := defaultConfig()
+	if len(c.Targets) != len(defaultLinks) {
+		t.Fatalf("Expected %v targets, but got %v", len(defaultLinks), len(c.Targets))
+	}
+	if err := c.validate(); err != nil {
+		t.Errorf("Expected the default config to be valid, but got %v", err)
+	}
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// targetStatus is how /api/status and the events show a target
+type targetStatus struct {
+	Name            string    `json:"name"`
+	URL             string    `json:"url"`
+	State           string    `json:"state"`
+	Code            int       `json:"code"`
+	Error           string    `json:"error,omitempty"`
+	LastCheck       time.Time `json:"last_check"`
+	Checks          int       `json:"checks"`
+	Uptime          float64   `json:"uptime"`
+	P50             float64   `json:"p50_ms"`
+	P95             float64   `json:"p95_ms"`
+	P99             float64   `json:"p99_ms"`
+	Failures        int       `json:"failures"`
+	LongestFailures int       `json:"longest_failures"`
+	Flapping        bool      `json:"flapping"`
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func newTargetStatus(s targetStats) targetStatus {
+	status := targetStatus{
+		Name:            s.target,
+		URL:             s.last.url,
+		State:           s.state.String(),
+		Code:            s.last.code,
+		LastCheck:       s.last.at,
+		Checks:          s.checks,
+		Uptime:          s.uptime,
+		P50:             milliseconds(s.p50),
+		P95:             milliseconds(s.p95),
+		P99:             milliseconds(s.p99),
+		Failures:        s.failures,
+		LongestFailures: s.longestFailures,
+		Flapping:        s.flapping,
+	}
+	if s.last.err != nil {
+		status.Error = s.last.err.Error()
+	}
+	return status
+}
+
+// stateChange is sent to the browsers when a target changes state
+type stateChange struct {
+	Target string `json:"target"`
+	// from is empty for the first state of a target
+	From   string       `json:"from"`
+	To     string       `json:"to"`
+	Status targetStatus `json:"status"`
+}
+
+// broker fans the state changes out to every connected browser,
+// a browser that doesn't keep up misses changes instead of blocking the monitor
+type broker struct {
+	mu          sync.Mutex
+	subscribers map[chan stateChange]bool
+	last        map[string]state
+	closed      bool
+}
+
+func newBroker() *broker {
+	return &broker{subscribers: map[chan stateChange]bool{}, last: map[string]state{}}
+}
+
+// subscribe returns the channel of changes and the function to stop listening,
+// the channel is closed when the broker closes
+func (b *broker) subscribe() (<-chan stateChange, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := make(chan stateChange, 16)
+	if b.closed {
+		close(c)
+		return c, func() {}
+	}
+	b.subscribers[c] = true
+
+	return c, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.subscribers[c] {
+			delete(b.subscribers, c)
+			close(c)
+		}
+	}
+}
+
+// publish sends the stats of a target when its state is not the one published last
+func (b *broker) publish(s targetStats) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	previous, seen := b.last[s.target]
+	if b.closed || (seen && previous == s.state) {
+		return
+	}
+	b.last[s.target] = s.state
+
+	change := stateChange{Target: s.target, To: s.state.String(), Status: newTargetStatus(s)}
+	if seen {
+		change.From = previous.String()
+	}
+	for c := range b.subscribers {
+		select {
+		case c <- change:
+		default:
+		}
+	}
+}
+
+// close ends every subscription, the event streams return and the server can shut down
+func (b *broker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for c := range b.subscribers {
+		delete(b.subscribers, c)
+		close(c)
+	}
+}
+
+// dashboard serves what the monitor sees to the rest of the team
+type dashboard struct {
+	history *history
+	events  *broker
+	started time.Time
+	// the checks on disk, /api/history is only served when it is set
+	store *store
+}
+
+func newDashboard(h *history) *dashboard {
+	return &dashboard{history: h, events: newBroker(), started: time.Now()}
+}
+
+// record is called with every check, after it was added to the history
+func (d *dashboard) record(r checkResult) {
+	if s, ok := d.history.statsFor(r.target); ok {
+		d.events.publish(s)
+	}
+}
+
+func (d *dashboard) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", d.serveIndex)
+	mux.HandleFunc("/api/status", d.serveStatus)
+	mux.HandleFunc("/metrics", d.serveMetrics)
+	mux.HandleFunc("/healthz", d.serveHealth)
+	mux.HandleFunc("/events", d.serveEvents)
+	if d.store != nil {
+		mux.HandleFunc("/api/history", d.serveHistory)
+	}
+	return mux
+}
+
+func (d *dashboard) statuses() []targetStatus {
+	statuses := []targetStatus{}
+	for _, s := range d.history.stats() {
+		statuses = append(statuses, newTargetStatus(s))
+	}
+	return statuses
+}
+
+func (d *dashboard) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := indexTemplate.Execute(w, d.statuses()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (d *dashboard) serveStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"started": d.started,
+		"targets": d.statuses(),
+	})
+}
+
+// maxHistoryChecks is the most checks /api/history returns at once
+const maxHistoryChecks = 1000
+
+// serveHistory returns the checks of a target from the store,
+// /api/history?target=google&from=2021-01-02T15:04:05Z&to=2021-01-03T15:04:05Z&limit=100
+// from, to and limit are optional, without them the first maxHistoryChecks checks of the target are returned.
+// When there are more checks next is the time of the first one left, it is the from of the next page.
+func (d *dashboard) serveHistory(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	target := q.Get("target")
+	if target == "" {
+		http.Error(w, "target is required", http.StatusBadRequest)
+		return
+	}
+	from, err := queryTime(q, "from")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := queryTime(q, "to")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := maxHistoryChecks
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		if n < limit {
+			limit = n
+		}
+	}
+
+	// one more check tells if there is a next page
+	results, err := d.store.results(target, from, to, limit+1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page := map[string]interface{}{"target": target}
+	if len(results) > limit {
+		page["next"] = results[limit].at
+		results = results[:limit]
+	}
+	checks := []storedResult{}
+	for _, r := range results {
+		checks = append(checks, newStoredResult(r))
+	}
+	page["checks"] = checks
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// queryTime reads an RFC 3339 time from the query, it is zero when missing
+func queryTime(q url.Values, name string) (time.Time, error) {
+	v := q.Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%v must be an RFC 3339 time like 2021-01-02T15:04:05Z", name)
+	}
+	return t, nil
+}
+
+func (d *dashboard) serveHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// serveMetrics writes the prometheus text format,
+// every metric has a target label with the name of the target
+func (d *dashboard) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	stats := d.history.stats()
+	var b strings.Builder
+
+	metric := func(name string, kind string, help string, value func(s targetStats) float64) {
+		fmt.Fprintf(&b, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, kind)
+		for _, s := range stats {
+			fmt.Fprintf(&b, "%v{target=%v} %v\n", name, labelValue(s.target), value(s))
+		}
+	}
+
+	metric("monitor_target_up", "gauge", "1 when the last check was up or degraded, 0 when it was down.", func(s targetStats) float64 {
+		if s.state == down {
+			return 0
+		}
+		return 1
+	})
+	metric("monitor_target_state", "gauge", "State of the last check, 0 down, 1 degraded, 2 up.", func(s targetStats) float64 {
+		return float64(s.state)
+	})
+	metric("monitor_target_checks", "gauge", "Checks in the history of the target.", func(s targetStats) float64 {
+		return float64(s.checks)
+	})
+	metric("monitor_target_uptime_ratio", "gauge", "Ratio of checks in the history that were not down.", func(s targetStats) float64 {
+		return s.uptime / 100
+	})
+	metric("monitor_target_failures", "gauge", "Consecutive failed checks.", func(s targetStats) float64 {
+		return float64(s.failures)
+	})
+	metric("monitor_target_flapping", "gauge", "1 when the target keeps changing state.", func(s targetStats) float64 {
+		if s.flapping {
+			return 1
+		}
+		return 0
+	})
+
+	fmt.Fprintf(&b, "# HELP monitor_target_latency_seconds Latency of the checks in the history.\n# TYPE monitor_target_latency_seconds summary\n")
+	for _, s := range stats {
+		for _, q := range []struct {
+			quantile string
+			value    time.Duration
+		}{{"0.5", s.p50}, {"0.95", s.p95}, {"0.99", s.p99}} {
+			fmt.Fprintf(&b, "monitor_target_latency_seconds{target=%v,quantile=%q} %v\n", labelValue(s.target), q.quantile, q.value.Seconds())
+		}
+		fmt.Fprintf(&b, "monitor_target_latency_seconds_sum{target=%v} %v\n", labelValue(s.target), s.latencySum.Seconds())
+		fmt.Fprintf(&b, "monitor_target_latency_seconds_count{target=%v} %v\n", labelValue(s.target), s.latencyCount)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(w, b.String())
+}
+
+// labelValue quotes a label value the way prometheus expects it
+func labelValue(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+	return `"` + s + `"`
+}
+
+// serveEvents streams the state changes with server sent events,
+// the current state of every target is sent first
+func (d *dashboard) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	changes, unsubscribe := d.events.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	for _, s := range d.statuses() {
+		writeEvent(w, stateChange{Target: s.Name, To: s.State, Status: s})
+	}
+	flusher.Flush()
+
+	// a comment now and then keeps proxies from closing an idle stream
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+			writeEvent(w, change)
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, change stateChange) {
+	bs, _ := json.Marshal(change)
+	fmt.Fprintf(w, "event: state\ndata: %s\n\n", bs)
+}
+
+// linkable reports whether a browser can open the url of a target,
+// html/template would replace tcp://, dns://, tls:// and port:// links with #ZgotmplZ
+func linkable(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+var indexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{"linkable": linkable}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Monitor</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 0.3em 0.8em; border-bottom: 1px solid #ddd; text-align: left; }
+.up { color: #2a7d2a; }
+.degraded { color: #b07d00; }
+.down { color: #c0392b; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Monitor</h1>
+<table>
+<thead><tr><th>target</th><th>state</th><th>code</th><th>uptime</th><th>p50</th><th>p95</th><th>p99</th><th>failures</th><th>last check</th><th>error</th></tr></thead>
+<tbody id="targets">
+{{range .}}<tr id="{{.Name}}">
+<td>{{if linkable .URL}}<a href="{{.URL}}">{{.Name}}</a>{{else}}<span title="{{.URL}}">{{.Name}}</span>{{end}}</td>
+<td class="{{.State}}">{{.State}}{{if .Flapping}} (flapping){{end}}</td>
+<td>{{.Code}}</td>
+<td>{{printf "%.2f" .Uptime}}%</td>
+<td>{{printf "%.0f" .P50}}ms</td>
+<td>{{printf "%.0f" .P95}}ms</td>
+<td>{{printf "%.0f" .P99}}ms</td>
+<td>{{.Failures}}</td>
+<td>{{.LastCheck.Format "15:04:05"}}</td>
+<td>{{.Error}}</td>
+</tr>
+{{end}}</tbody>
+</table>
+<script>
+// rows are replaced when the state of a target changes
+const events = new EventSource("/events");
+events.addEventListener("state", (e) => {
+	const s = JSON.parse(e.data).status;
+	let row = document.getElementById(s.name);
+	if (!row) {
+		row = document.createElement("tr");
+		row.id = s.name;
+		document.getElementById("targets").appendChild(row);
+	}
+	const cells = [s.name, s.state + (s.flapping ? " (flapping)" : ""), s.code, s.uptime.toFixed(2) + "%",
+		s.p50_ms.toFixed(0) + "ms", s.p95_ms.toFixed(0) + "ms", s.p99_ms.toFixed(0) + "ms",
+		s.failures, new Date(s.last_check).toLocaleTimeString(), s.error || ""];
+	row.replaceChildren(...cells.map((text, i) => {
+		const td = document.createElement("td");
+		td.textContent = text;
+		if (i === 1) td.className = s.state;
+		return td;
+	}));
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testDashboard() *dashboard {
+	h := newHistory(10)
+	h.record(checkResult{target: "a", url: "http://a", state: up, code: 200, latency: 20 * time.Millisecond, at: time.Now()})
+	h.record(checkResult{target: `b"quoted"`, url: "http://b", state: down, err: errors.New("refused"), at: time.Now()})
+	return newDashboard(h)
+}
+
+func get(t *testing.T, server *httptest.Server, path string) (*http.Response, string) {
+	resp, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	bs, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(bs)
+}
+
+func TestDashboardStatus(t *testing.T) {
+	server := httptest.NewServer(testDashboard().handler())
+	defer server.Close()
+
+	resp, body := get(t, server, "/api/status")
+	if resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected json, but got %v", resp.Header.Get("Content-Type"))
+	}
+
+	status := struct {
+		Targets []targetStatus `json:"targets"`
+	}{}
+	if err := json.Unmarshal([]byte(body), &status); err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Targets) != 2 || status.Targets[0].Name != "a" || status.Targets[0].State != "up" || status.Targets[0].P50 != 20 {
+		t.Errorf("Expected a up in 20ms first, but got %+v", status.Targets)
+	}
+	if b := status.Targets[1]; b.State != "down" || b.Error != "refused" || b.Failures != 1 {
+		t.Errorf("Expected b down with its error, but got %+v", b)
+	}
+}
+
+func TestDashboardMetrics(t *testing.T) {
+	server := httptest.NewServer(testDashboard().handler())
+	defer server.Close()
+
+	_, body := get(t, server, "/metrics")
+	for _, line := range []string{
+		`# TYPE monitor_target_up gauge`,
+		`monitor_target_up{target="a"} 1`,
+		`monitor_target_up{target="b\"quoted\""} 0`,
+		`monitor_target_state{target="a"} 2`,
+		`monitor_target_uptime_ratio{target="b\"quoted\""} 0`,
+		`monitor_target_latency_seconds{target="a",quantile="0.99"} 0.02`,
+		`monitor_target_latency_seconds_sum{target="a"} 0.02`,
+		`monitor_target_latency_seconds_count{target="a"} 1`,
+		`monitor_target_latency_seconds_count{target="b\"quoted\""} 0`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected the metrics to contain %v, but got\n%v", line, body)
+		}
+	}
+}
+
+func TestDashboardIndexAndHealth(t *testing.T) {
+	d := testDashboard()
+	d.history.record(checkResult{target: "db", url: "tcp://db:5432", state: up, at: time.Now()})
+	server := httptest.NewServer(d.handler())
+	defer server.Close()
+
+	if _, body := get(t, server, "/"); !strings.Contains(body, `<a href="http://a">a</a>`) || !strings.Contains(body, "b&#34;quoted&#34;") {
+		t.Errorf("Expected the page to list the escaped targets, but got %v", body)
+	}
+	if _, body := get(t, server, "/"); strings.Contains(body, "ZgotmplZ") || !strings.Contains(body, `<span title="tcp://db:5432">db</span>`) {
+		t.Errorf("Expected the tcp target without a broken link, but got %v", body)
+	}
+	if resp, body := get(t, server, "/healthz"); resp.StatusCode != http.StatusOK || body != "ok\n" {
+		t.Errorf("Expected ok, but got %v %q", resp.StatusCode, body)
+	}
+	if resp, _ := get(t, server, "/missing"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404, but got %v", resp.StatusCode)
+	}
+}
+
+// readEvent reads the data of the next event of a stream
+func readEvent(t *testing.T, r *bufio.Reader) stateChange {
+	change := stateChange{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Expected an event, but got %v", err)
+		}
+		if strings.HasPrefix(line, "data: ") {
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &change); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if line == "\n" && change.Target != "" {
+			return change
+		}
+	}
+}
+
+func TestDashboardEvents(t *testing.T) {
+	d := testDashboard()
+	server := httptest.NewServer(d.handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Expected an event stream, but got %v", resp.Header.Get("Content-Type"))
+	}
+
+	r := bufio.NewReader(resp.Body)
+	if first := readEvent(t, r); first.Target != "a" || first.To != "up" {
+		t.Errorf("Expected the current state of a first, but got %+v", first)
+	}
+	readEvent(t, r)
+
+	// the first check of a target is a change, the same state again is not
+	d.history.record(checkResult{target: "a", state: up, code: 200, at: time.Now()})
+	d.record(checkResult{target: "a"})
+	d.history.record(checkResult{target: "a", state: down, err: errors.New("timeout"), at: time.Now()})
+	d.record(checkResult{target: "a"})
+
+	if change := readEvent(t, r); change.Target != "a" || change.To != "up" || change.From != "" {
+		t.Errorf("Expected the first state of a, but got %+v", change)
+	}
+	if change := readEvent(t, r); change.From != "up" || change.To != "down" || change.Status.Error != "timeout" {
+		t.Errorf("Expected a to go from up to down, but got %+v", change)
+	}
+
+	// closing the broker ends the stream
+	d.events.close()
+	if _, err := ioutil.ReadAll(r); err != nil {
+		t.Errorf("Expected the stream to end, but got %v", err)
+	}
+}
+
+func TestBrokerDropsSlowSubscribers(t *testing.T) {
+	b := newBroker()
+	changes, unsubscribe := b.subscribe()
+	defer unsubscribe()
+
+	// more changes than the channel holds must not block
+	for i := 0; i < 100; i++ {
+		s := up
+		if i%2 == 0 {
+			s = down
+		}
+		b.publish(targetStats{target: "a", state: s})
+	}
+	if len(changes) != cap(changes) {
+		t.Errorf("Expected a full channel, but got %v changes", len(changes))
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHistorySize = 1000
+	// flapping is decided on the last flapWindow checks, a target starts flapping
+	// when more than flapHigh of them changed state and stops when fewer than
+	// flapLow did, the gap keeps it from going in and out of flapping
+	flapWindow = 20
+	flapHigh   = 0.5
+	flapLow    = 0.25
+)
+
+// ring keeps the last len(results) checks, the oldest is overwritten first
+type ring struct {
+	results []checkResult
+	next    int
+	full    bool
+}
+
+func newRing(size int) *ring {
+	return &ring{results: make([]checkResult, size)}
+}
+
+func (r *ring) add(c checkResult) {
+	r.results[r.next] = c
+	r.next = (r.next + 1) % len(r.results)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *ring) len() int {
+	if r.full {
+		return len(r.results)
+	}
+	return r.next
+}
+
+// all returns the checks from the oldest to the newest
+func (r *ring) all() []checkResult {
+	if !r.full {
+		return append([]checkResult{}, r.results[:r.next]...)
+	}
+	return append(append([]checkResult{}, r.results[r.next:]...), r.results[:r.next]...)
+}
+
+// last returns up to n of the newest checks, from the oldest to the newest
+func (r *ring) last(n int) []checkResult {
+	all := r.all()
+	if n < len(all) {
+		return all[len(all)-n:]
+	}
+	return all
+}
+
+// alert is raised when a target settles in a new state,
+// or when it starts flapping
+type alert struct {
+	target   string
+	from, to state
+	flapping bool
+	result   checkResult
+}
+
+func (a alert) String() string {
+	if a.flapping {
+		return fmt.Sprintf("ALERT %v is flapping, alerts are suppressed until it settles", a.target)
+	}
+	return fmt.Sprintf("ALERT %v is %v (was %v): %v", a.target, a.to, a.from, a.result)
+}
+
+// targetHistory is the history of a single target
+type targetHistory struct {
+	checks *ring
+	// consecutive failed checks, and the longest run of them seen
+	failures        int
+	longestFailures int
+	flapping        bool
+	// the last state an alert was raised for, targets are assumed up when the monitor starts
+	alerted state
+}
+
+// history records every check of every target, it is safe to use from many goroutines
+type history struct {
+	mu      sync.Mutex
+	size    int
+	targets map[string]*targetHistory
+}
+
+func newHistory(size int) *history {
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	return &history{size: size, targets: map[string]*targetHistory{}}
+}
+
+// record adds a check to the history of its target and returns
+// the alert it raises, or nil when there is nothing to report
+func (h *history) record(r checkResult) *alert {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t, ok := h.targets[r.target]
+	if !ok {
+		t = &targetHistory{checks: newRing(h.size), alerted: up}
+		h.targets[r.target] = t
+	}
+	t.checks.add(r)
+
+	if r.state == down {
+		t.failures++
+		if t.failures > t.longestFailures {
+			t.longestFailures = t.failures
+		}
+	} else {
+		t.failures = 0
+	}
+
+	changes := flapRate(t.checks.last(flapWindow + 1))
+	switch {
+	case !t.flapping && changes > flapHigh:
+		t.flapping = true
+		return &alert{target: r.target, from: t.alerted, to: r.state, flapping: true, result: r}
+	case t.flapping && changes < flapLow:
+		t.flapping = false
+	case t.flapping:
+		return nil
+	}
+
+	if r.state == t.alerted {
+		return nil
+	}
+	a := &alert{target: r.target, from: t.alerted, to: r.state, result: r}
+	t.alerted = r.state
+	return a
+}
+
+// flapRate is the fraction of checks that changed state from the one before,
+// it is measured over a full window even when there are fewer checks,
+// so a single change right after starting isn't taken for flapping
+func flapRate(checks []checkResult) float64 {
+	changes := 0
+	for i := 1; i < len(checks); i++ {
+		if checks[i].state != checks[i-1].state {
+			changes++
+		}
+	}
+
+	n := len(checks) - 1
+	if n < flapWindow {
+		n = flapWindow
+	}
+	return float64(changes) / float64(n)
+}
+
+// targetStats sums up the history of a target
+type targetStats struct {
+	target string
+	checks int
+	state  state
+	// uptime is the percentage of checks that were not down
+	uptime        float64
+	p50, p95, p99 time.Duration
+	// how many latencies the percentiles are taken from and their total
+	latencyCount int
+	latencySum   time.Duration
+	// failures is the current streak of failed checks
+	failures        int
+	longestFailures int
+	flapping        bool
+	last            checkResult
+}
+
+func (s targetStats) String() string {
+	flapping := ""
+	if s.flapping {
+		flapping = " flapping"
+	}
+	return fmt.Sprintf("%v: %v%v, %.2f%% up over %d checks, p50 %v p95 %v p99 %v, %d failing (longest %d)",
+		s.target, s.state, flapping, s.uptime, s.checks,
+		s.p50.Round(time.Millisecond), s.p95.Round(time.Millisecond), s.p99.Round(time.Millisecond),
+		s.failures, s.longestFailures)
+}
+
+// stats returns the stats of every target sorted by name
+func (h *history) stats() []targetStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stats := []targetStats{}
+	for name, t := range h.targets {
+		stats = append(stats, t.stats(name))
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].target < stats[j].target
+	})
+	return stats
+}
+
+// statsFor returns the stats of a single target
+func (h *history) statsFor(target string) (targetStats, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t, ok := h.targets[target]
+	if !ok {
+		return targetStats{}, false
+	}
+	return t.stats(target), true
+}
+
+func (t *targetHistory) stats(name string) targetStats {
+	checks := t.checks.all()
+	s := targetStats{
+		target:          name,
+		checks:          len(checks),
+		failures:        t.failures,
+		longestFailures: t.longestFailures,
+		flapping:        t.flapping,
+	}
+	if len(checks) == 0 {
+		return s
+	}
+	s.last = checks[len(checks)-1]
+	s.state = s.last.state
+
+	// only checks that got an answer have a latency worth measuring,
+	// a timeout would only show the timeout. an http status is an answer
+	// even when it is down, the other probes only answer when they are not down
+	available := 0
+	latencies := []time.Duration{}
+	for _, c := range checks {
+		if c.state != down {
+			available++
+		}
+		if c.code != 0 || c.state != down {
+			latencies = append(latencies, c.latency)
+			s.latencySum += c.latency
+		}
+	}
+	s.uptime = 100 * float64(available) / float64(len(checks))
+	s.latencyCount = len(latencies)
+
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	s.p50 = percentile(latencies, 50)
+	s.p95 = percentile(latencies, 95)
+	s.p99 = percentile(latencies, 99)
+	return s
+}
+
+// percentile uses the nearest rank method on sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
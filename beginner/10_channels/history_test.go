@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func result(target string, s state, latency time.Duration) checkResult {
+	code := 200
+	if s == down {
+		code = 0
+	}
+	return checkResult{target: target, state: s, code: code, latency: latency, at: time.Now()}
+}
+
+func TestRingKeepsTheNewestChecks(t *testing.T) {
+	r := newRing(3)
+	for i := 1; i <= 5; i++ {
+		r.add(checkResult{code: i})
+	}
+
+	all := r.all()
+	if r.len() != 3 || len(all) != 3 || all[0].code != 3 || all[2].code != 5 {
+		t.Errorf("Expected checks 3 to 5, but got %v", all)
+	}
+	if last := r.last(2); len(last) != 2 || last[0].code != 4 {
+		t.Errorf("Expected checks 4 and 5, but got %v", last)
+	}
+}
+
+func TestStats(t *testing.T) {
+	h := newHistory(100)
+	for i := 1; i <= 100; i++ {
+		s := up
+		// checks 91 to 100 fail
+		if i > 90 {
+			s = down
+		}
+		h.record(result("a", s, time.Duration(i)*time.Millisecond))
+	}
+
+	s, ok := h.statsFor("a")
+	if !ok {
+		t.Fatal("Expected stats for a")
+	}
+	if s.checks != 100 || s.uptime != 90 || s.state != down {
+		t.Errorf("Expected 90%% uptime over 100 checks, but got %v", s)
+	}
+	// the failed checks have no latency, so the percentiles are over checks 1 to 90
+	if s.p50 != 45*time.Millisecond || s.p95 != 86*time.Millisecond || s.p99 != 90*time.Millisecond {
+		t.Errorf("Expected p50 45ms, p95 86ms and p99 90ms, but got %v %v %v", s.p50, s.p95, s.p99)
+	}
+	if s.failures != 10 || s.longestFailures != 10 {
+		t.Errorf("Expected 10 failures in a row, but got %v", s.failures)
+	}
+
+	h.record(result("a", up, time.Millisecond))
+	if s, _ := h.statsFor("a"); s.failures != 0 || s.longestFailures != 10 {
+		t.Errorf("Expected the streak to reset and the longest to stay, but got %v and %v", s.failures, s.longestFailures)
+	}
+
+	if _, ok := h.statsFor("b"); ok {
+		t.Error("Expected no stats for an unknown target")
+	}
+}
+
+func TestStatsWithoutStatusCodes(t *testing.T) {
+	h := newHistory(10)
+	// tcp, dns, tls and port probes have no status code
+	for _, c := range []checkResult{
+		{target: "db", state: up, latency: 10 * time.Millisecond},
+		{target: "db", state: degraded, latency: 30 * time.Millisecond, err: errors.New("slow")},
+		{target: "db", state: up, latency: 20 * time.Millisecond},
+		{target: "db", state: down, latency: 5 * time.Second, err: errors.New("timeout")},
+	} {
+		c.at = time.Now()
+		h.record(c)
+	}
+
+	s, _ := h.statsFor("db")
+	if s.p50 != 20*time.Millisecond || s.p99 != 30*time.Millisecond {
+		t.Errorf("Expected p50 20ms and p99 30ms without the timeout, but got %v and %v", s.p50, s.p99)
+	}
+}
+
+func TestRecordAlertsOnStateChanges(t *testing.T) {
+	h := newHistory(100)
+
+	if a := h.record(result("a", up, 0)); a != nil {
+		t.Errorf("Expected no alert for a target that starts up, but got %v", a)
+	}
+	a := h.record(result("a", down, 0))
+	if a == nil || a.from != up || a.to != down {
+		t.Fatalf("Expected an alert from up to down, but got %v", a)
+	}
+	if a := h.record(result("a", down, 0)); a != nil {
+		t.Errorf("Expected a single alert while the target stays down, but got %v", a)
+	}
+	if a := h.record(result("a", up, 0)); a == nil || a.to != up {
+		t.Errorf("Expected an alert when the target comes back, but got %v", a)
+	}
+}
+
+func TestRecordSuppressesAlertsWhileFlapping(t *testing.T) {
+	h := newHistory(100)
+
+	alerts := []*alert{}
+	for i := 0; i < 40; i++ {
+		s := up
+		if i%2 == 1 {
+			s = down
+		}
+		if a := h.record(result("a", s, 0)); a != nil {
+			alerts = append(alerts, a)
+		}
+	}
+
+	flapping := 0
+	for _, a := range alerts {
+		if a.flapping {
+			flapping++
+		}
+	}
+	if flapping != 1 || len(alerts) > flapWindow/2+1 {
+		t.Errorf("Expected a single flapping alert and no alerts after it, but got %v", alerts)
+	}
+	if s, _ := h.statsFor("a"); !s.flapping {
+		t.Error("Expected the target to be flapping")
+	}
+
+	// once it settles the alerts come back
+	settled := []*alert{}
+	for i := 0; i < flapWindow; i++ {
+		if a := h.record(result("a", down, 0)); a != nil {
+			settled = append(settled, a)
+		}
+	}
+	if s, _ := h.statsFor("a"); s.flapping {
+		t.Error("Expected the target to stop flapping")
+	}
+	if len(settled) != 1 || settled[0].to != down {
+		t.Errorf("Expected a single alert for the settled state, but got %v", settled)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	if p := percentile(nil, 50); p != 0 {
+		t.Errorf("Expected 0 without latencies, but got %v", p)
+	}
+	one := []time.Duration{time.Second}
+	if p := percentile(one, 99); p != time.Second {
+		t.Errorf("Expected the only latency, but got %v", p)
+	}
+}
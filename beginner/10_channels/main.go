@@ -1,69 +1,123 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
 func main() {
-	links := []string{
-		"https://google.com",
-		"https://facebook.com",
-		"https://stackoverflow.com",
-		"https://golang.org",
-		"https://amazon.com",
+	configFile := flag.String("config", "", "YAML or JSON file with the targets, the default links are checked when empty")
+	historySize := flag.Int("history", defaultHistorySize, "number of checks remembered for each target")
+	listen := flag.String("listen", "localhost:8080", "address of the status dashboard, it is disabled when empty")
+	dataDir := flag.String("data", "", "directory where the checks are kept between runs, nothing is kept when empty")
+	segmentSize := flag.Int64("segment-size", defaultSegmentSize, "bytes written to a file of the data directory before the next one starts")
+	retention := flag.Duration("retention", defaultRetention, "how long the checks are kept in the data directory, 0 keeps them forever")
+	retentionSize := flag.Int64("retention-size", defaultRetentionBytes, "bytes the data directory can take before the oldest checks are deleted, 0 has no limit")
+	flag.Parse()
+
+	cfg := defaultConfig()
+	if *configFile != "" {
+		var err error
+		cfg, err = loadConfig(*configFile)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
 	}
 
-	// sequential check
-	// for _, link := range links {
-	// 	checkLink(link)
-	// }
+	m, err := newMonitor(cfg)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	notifications, err := newDispatcher(cfg)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	// ctrl-c or a SIGTERM stops new checks, the ones already running are drained
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// unwaited goroutine
-	// for _, link := range links {
-	// 	go checkLink(link)
-	// }
+	// the first version started a goroutine for every link and sent the link
+	// back on a channel when the check was done, then a loop received from the
+	// channel and started the next check after a delay:
+	//
+	//	for l := range c {
+	//		go func(link string) {
+	//			time.Sleep(5 * time.Second)
+	//			checkLink(link, c)
+	//		}(l)
+	//	}
+	//
+	// now each target has a goroutine that waits its own interval,
+	// the channel carries the results and is closed when the monitor stops
 
 	// goroutine with channel
-	c := make(chan string)
-	for _, link := range links {
-		go checkLink(link, c)
-	}
+	c := make(chan checkResult)
+	go m.run(ctx, c)
 
-	// print up to 5 received values
-	// for i := 0; i < len(links); i++ {
-	// 	fmt.Println(<-c)
-	// }
+	h := newHistory(*historySize)
+	d := newDashboard(h)
 
-	// infinite loop
-	// for {
-	// 	go checkLink(<-c, c)
-	// }
+	// the checks of the previous runs rebuild the history,
+	// they were already alerted on so the alerts are ignored
+	if *dataDir != "" {
+		s, err := openStore(*dataDir, storeOptions{segmentSize: *segmentSize, retention: *retention, retentionBytes: *retentionSize})
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		defer s.close()
+		if err := s.replay(func(r checkResult) { h.record(r) }); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		d.store = s
+	}
 
-	// infinite loop
-	// runs every time c receives a value
-	for l := range c {
-		// go checkLink(l, c)
-		// using a function literal to include a delay
-		go func(link string) {
-			time.Sleep(5 * time.Second)
-			checkLink(link, c) // receive l as an argument
-			// checkLink(l, c) / /capture l from the other goroutine
-			// we can't do this because the value of l changes
-			// we shouldn't share variables between goroutines
-			// if we want to do that we should use a channel
-		}(l)
+	server := &http.Server{Addr: *listen, Handler: d.handler()}
+	if *listen != "" {
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fmt.Println("Error:", err)
+			}
+		}()
 	}
-}
 
-func checkLink(link string, c chan string) {
-	_, err := http.Get(link)
-	if err != nil {
-		fmt.Println(link, "might be down!")
-		c <- link
-		return
+	// runs every time c receives a value, until the monitor closes it
+	for r := range c {
+		fmt.Println(r)
+		if d.store != nil {
+			if err := d.store.append(r); err != nil {
+				fmt.Println("Error:", err)
+			}
+		}
+		if a := h.record(r); a != nil {
+			fmt.Println(a)
+			notifications.dispatch(ctx, *a)
+		}
+		d.record(r)
+	}
+
+	// the event streams never end on their own, they are closed
+	// before the server waits for the open requests
+	d.events.close()
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdown)
+	// alerts that are still being sent, ctx is done so they are not retried
+	notifications.wait()
+
+	fmt.Println("stopped")
+	for _, s := range h.stats() {
+		fmt.Println(" ", s)
 	}
-	fmt.Println(link, "is up!")
-	c <- link
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// monitor checks every target on its own interval and sends the results on a channel
+type monitor struct {
+	targets []target
+	probes  map[string]Probe
+}
+
+func newMonitor(c config) (*monitor, error) {
+	m := &monitor{targets: c.Targets, probes: map[string]Probe{}}
+	client := newClient(c.Client)
+	for _, t := range c.Targets {
+		p, err := newProbe(t, client)
+		if err != nil {
+			return nil, err
+		}
+		m.probes[t.Name] = p
+	}
+	return m, nil
+}
+
+// run starts a goroutine for each target and blocks until ctx is done,
+// then it waits for the checks that already started and closes results,
+// so ranging over results sees every check that was made
+func (m *monitor) run(ctx context.Context, results chan<- checkResult) {
+	var wg sync.WaitGroup
+	for _, t := range m.targets {
+		wg.Add(1)
+		// t is passed as an argument, the loop variable changes
+		// while the goroutine is running
+		go func(t target) {
+			defer wg.Done()
+			m.watch(ctx, t, results)
+		}(t)
+	}
+
+	wg.Wait()
+	close(results)
+}
+
+func (m *monitor) watch(ctx context.Context, t target, results chan<- checkResult) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		results <- check(m.probes[t.Name], t)
+		timer.Reset(t.Interval)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMonitorDrainsInFlightChecks(t *testing.T) {
+	started := make(chan bool, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	cfg := config{Targets: []target{{URL: server.URL, Interval: time.Hour}}}
+	cfg.setDefaults()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := make(chan checkResult)
+	m, err := newMonitor(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go m.run(ctx, c)
+
+	// stop the monitor while the first check is running
+	<-started
+	cancel()
+
+	results := []checkResult{}
+	for r := range c {
+		results = append(results, r)
+	}
+	if len(results) != 1 || results[0].state != up {
+		t.Errorf("Expected the running check to finish, but got %v", results)
+	}
+}
+
+func TestMonitorChecksOnEveryInterval(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	cfg := config{Targets: []target{
+		{Name: "fast", URL: server.URL, Interval: 10 * time.Millisecond},
+		{Name: "slow", URL: server.URL, Interval: time.Hour},
+	}}
+	cfg.setDefaults()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	c := make(chan checkResult)
+	m, err := newMonitor(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go m.run(ctx, c)
+
+	count := map[string]int{}
+	for r := range c {
+		count[r.target]++
+	}
+	if count["slow"] != 1 || count["fast"] < 5 {
+		t.Errorf("Expected a single slow check and several fast ones, but got %v", count)
+	}
+}
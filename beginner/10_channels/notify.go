@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Notifier sends alerts somewhere people will see them,
+// a new sink only needs a type that implements it and a case in newNotifier
+type Notifier interface {
+	Notify(ctx context.Context, a alert) error
+}
+
+const (
+	defaultRetries = 3
+	defaultBackoff = time.Second
+	defaultDedup   = 10 * time.Minute
+	// time for a single attempt to send an alert
+	notifyTimeout = 10 * time.Second
+)
+
+// notifierConfig configures a sink, only the fields of its type are used
+//
+//	notifiers:
+//	  - name: ops
+//	    type: webhook
+//	    url: https://hooks.example.com/monitor
+//	  - name: mail
+//	    type: smtp
+//	    addr: smtp.example.com:587
+//	    from: monitor@example.com
+//	    to: [oncall@example.com]
+//	  - name: page
+//	    type: command
+//	    command: [/usr/local/bin/page, --urgent]
+//	  - name: audit
+//	    type: log
+//	    file: alerts.log
+//	    retries: 0
+type notifierConfig struct {
+	Name string `yaml:"name" json:"name"`
+	// webhook, smtp, command or log
+	Type string `yaml:"type" json:"type"`
+
+	// webhook
+	URL string `yaml:"url" json:"url"`
+	// smtp, the login is only used when the username is set
+	Addr     string   `yaml:"addr" json:"addr"`
+	From     string   `yaml:"from" json:"from"`
+	To       []string `yaml:"to" json:"to"`
+	Username string   `yaml:"username" json:"username"`
+	Password string   `yaml:"password" json:"password"`
+	// command, the alert is given as JSON on stdin and in MONITOR_ environment variables
+	Command []string `yaml:"command" json:"command"`
+	// log
+	File string `yaml:"file" json:"file"`
+
+	// retries after the first attempt fails, the wait doubles after every attempt
+	Retries *int          `yaml:"retries" json:"retries"`
+	Backoff time.Duration `yaml:"backoff" json:"backoff"`
+}
+
+func newNotifier(c notifierConfig) (Notifier, error) {
+	switch c.Type {
+	case "webhook":
+		if c.URL == "" {
+			return nil, fmt.Errorf("notifier %q: webhook needs a url", c.Name)
+		}
+		return newWebhookNotifier(c.URL), nil
+	case "smtp":
+		if c.Addr == "" || c.From == "" || len(c.To) == 0 {
+			return nil, fmt.Errorf("notifier %q: smtp needs addr, from and to", c.Name)
+		}
+		return &smtpNotifier{addr: c.Addr, from: c.From, to: c.To, username: c.Username, password: c.Password}, nil
+	case "command":
+		if len(c.Command) == 0 {
+			return nil, fmt.Errorf("notifier %q: command needs a command", c.Name)
+		}
+		return &commandNotifier{command: c.Command}, nil
+	case "log":
+		if c.File == "" {
+			return nil, fmt.Errorf("notifier %q: log needs a file", c.Name)
+		}
+		return &logNotifier{filename: c.File}, nil
+	}
+	return nil, fmt.Errorf("notifier %q: unknown type %q", c.Name, c.Type)
+}
+
+// retryPolicy is how many times and how long apart a failed alert is sent again
+type retryPolicy struct {
+	retries int
+	backoff time.Duration
+}
+
+// route is a notifier with its retry policy
+type route struct {
+	name     string
+	notifier Notifier
+	retry    retryPolicy
+}
+
+// dispatcher sends every alert to the notifiers of its target,
+// each notifier is called in its own goroutine so a slow sink doesn't delay the others
+type dispatcher struct {
+	routes map[string]route
+	// notifiers of each target, targets that are not here use every notifier
+	targets map[string][]string
+	dedup   time.Duration
+
+	mu sync.Mutex
+	// the last alert sent for each target
+	sent map[string]alert
+	wg   sync.WaitGroup
+	// errors are reported here, it prints them by default
+	errors func(name string, a alert, err error)
+}
+
+func newDispatcher(c config) (*dispatcher, error) {
+	d := &dispatcher{
+		routes:  map[string]route{},
+		targets: map[string][]string{},
+		dedup:   c.Dedup,
+		sent:    map[string]alert{},
+		errors: func(name string, a alert, err error) {
+			fmt.Printf("Error: notifier %v could not send the alert for %v: %v\n", name, a.target, err)
+		},
+	}
+	if d.dedup == 0 {
+		d.dedup = defaultDedup
+	}
+
+	for _, nc := range c.Notifiers {
+		if nc.Name == "" {
+			return nil, fmt.Errorf("notifier of type %q has no name", nc.Type)
+		}
+		if _, ok := d.routes[nc.Name]; ok {
+			return nil, fmt.Errorf("notifier %q: duplicate name", nc.Name)
+		}
+
+		n, err := newNotifier(nc)
+		if err != nil {
+			return nil, err
+		}
+		retry := retryPolicy{retries: defaultRetries, backoff: nc.Backoff}
+		if nc.Retries != nil {
+			retry.retries = *nc.Retries
+		}
+		if retry.backoff == 0 {
+			retry.backoff = defaultBackoff
+		}
+		d.routes[nc.Name] = route{name: nc.Name, notifier: n, retry: retry}
+	}
+
+	for _, t := range c.Targets {
+		for _, name := range t.Notify {
+			if _, ok := d.routes[name]; !ok {
+				return nil, fmt.Errorf("target %q: unknown notifier %q", t.Name, name)
+			}
+		}
+		if len(t.Notify) > 0 {
+			d.targets[t.Name] = t.Notify
+		}
+	}
+	return d, nil
+}
+
+// dispatch sends the alert in the background, an alert that repeats the last one
+// sent for its target within the dedup window is dropped, a change never is.
+// Retries stop waiting when ctx is done.
+func (d *dispatcher) dispatch(ctx context.Context, a alert) {
+	d.mu.Lock()
+	last, ok := d.sent[a.target]
+	if ok && last.to == a.to && last.flapping == a.flapping && a.result.at.Sub(last.result.at) < d.dedup {
+		d.mu.Unlock()
+		return
+	}
+	d.sent[a.target] = a
+	d.mu.Unlock()
+
+	for _, r := range d.routesFor(a.target) {
+		d.wg.Add(1)
+		go func(r route) {
+			defer d.wg.Done()
+			if err := r.send(ctx, a); err != nil {
+				d.errors(r.name, a, err)
+			}
+		}(r)
+	}
+}
+
+func (d *dispatcher) routesFor(target string) []route {
+	routes := []route{}
+	names, ok := d.targets[target]
+	if !ok {
+		for _, r := range d.routes {
+			routes = append(routes, r)
+		}
+		return routes
+	}
+
+	for _, name := range names {
+		routes = append(routes, d.routes[name])
+	}
+	return routes
+}
+
+// wait blocks until every alert that was dispatched is sent or gave up
+func (d *dispatcher) wait() {
+	d.wg.Wait()
+}
+
+// send tries the notifier until it succeeds, runs out of retries or ctx is done,
+// an attempt that started is not cut short by ctx
+func (r route) send(ctx context.Context, a alert) error {
+	backoff := r.retry.backoff
+
+	var err error
+	for attempt := 0; attempt <= r.retry.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("stopped after %d attempts: %w", attempt, err)
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		err = r.notifier.Notify(ctx, a)
+		cancel()
+		if err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("gave up after %d attempts: %w", r.retry.retries+1, err)
+}
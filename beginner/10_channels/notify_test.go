@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testAlert(target string, to state) alert {
+	r := checkResult{target: target, url: "http://" + target, state: to, at: time.Now()}
+	if to == down {
+		r.err = errors.New("connection refused")
+	}
+	return alert{target: target, from: up, to: to, result: r}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "notify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	payloads := make(chan alertPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := alertPayload{}
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&p)
+		payloads <- p
+	}))
+	defer server.Close()
+
+	if err := newWebhookNotifier(server.URL).Notify(context.Background(), testAlert("a", down)); err != nil {
+		t.Fatal(err)
+	}
+	if p := <-payloads; p.Target != "a" || p.From != "up" || p.To != "down" || p.Error != "connection refused" {
+		t.Errorf("Expected the alert for a, but got %+v", p)
+	}
+}
+
+func TestWebhookNotifierFailsOnErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	if err := newWebhookNotifier(server.URL).Notify(context.Background(), testAlert("a", down)); err == nil {
+		t.Error("Expected an error for a 502, but got nil")
+	}
+}
+
+// fakeSMTP speaks just enough SMTP for net/smtp and keeps the messages it receives
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	messages := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, messages)
+		}
+	}()
+	return l.Addr().String(), messages
+}
+
+func serveSMTP(conn net.Conn, messages chan<- string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+
+	reply("220 fake ESMTP")
+	var envelope, data strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 fake")
+		case strings.HasPrefix(cmd, "MAIL FROM"), strings.HasPrefix(cmd, "RCPT TO"):
+			envelope.WriteString(strings.TrimSpace(line) + "\n")
+			reply("250 ok")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			messages <- envelope.String() + data.String()
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, messages := fakeSMTP(t)
+
+	n := &smtpNotifier{addr: addr, from: "monitor@example.com", to: []string{"oncall@example.com", "ops@example.com"}}
+	if err := n.Notify(context.Background(), testAlert("a", down)); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-messages
+	for _, want := range []string{
+		"MAIL FROM:<monitor@example.com>",
+		"RCPT TO:<oncall@example.com>",
+		"RCPT TO:<ops@example.com>",
+		"Subject: [monitor] a is down",
+		"connection refused",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Expected the message to contain %q, but got\n%v", want, msg)
+		}
+	}
+}
+
+func TestCommandNotifier(t *testing.T) {
+	out := filepath.Join(tempDir(t), "out")
+
+	n := &commandNotifier{command: []string{"sh", "-c", `echo "$MONITOR_TARGET $MONITOR_TO" > "$0"; cat >> "$0"`, out}}
+	if err := n.Notify(context.Background(), testAlert("a", down)); err != nil {
+		t.Fatal(err)
+	}
+
+	bs, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitN(string(bs), "\n", 2)
+	if lines[0] != "a down" || !strings.Contains(lines[1], `"target":"a"`) {
+		t.Errorf("Expected the environment and the JSON on stdin, but got %q", bs)
+	}
+
+	failing := &commandNotifier{command: []string{"sh", "-c", "echo broken; exit 3"}}
+	if err := failing.Notify(context.Background(), testAlert("a", down)); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Expected the error to include the output, but got %v", err)
+	}
+}
+
+func TestLogNotifier(t *testing.T) {
+	filename := filepath.Join(tempDir(t), "alerts.log")
+	n := &logNotifier{filename: filename}
+
+	n.Notify(context.Background(), testAlert("a", down))
+	n.Notify(context.Background(), testAlert("a", up))
+
+	bs, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(bs)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"to":"down"`) || !strings.Contains(lines[1], `"to":"up"`) {
+		t.Errorf("Expected two alerts, one per line, but got %q", bs)
+	}
+}
+
+// fakeNotifier records the alerts and fails the first failures calls
+type fakeNotifier struct {
+	mu       sync.Mutex
+	alerts   []alert
+	calls    int32
+	failures int32
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, a alert) error {
+	if atomic.AddInt32(&f.calls, 1) <= f.failures {
+		return errors.New("try again")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.alerts = append(f.alerts, a)
+	return nil
+}
+
+func (f *fakeNotifier) received() []alert {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]alert{}, f.alerts...)
+}
+
+func testDispatcher(routes map[string]*fakeNotifier, targets map[string][]string) *dispatcher {
+	d := &dispatcher{
+		routes:  map[string]route{},
+		targets: targets,
+		dedup:   time.Minute,
+		sent:    map[string]alert{},
+		errors:  func(string, alert, error) {},
+	}
+	for name, n := range routes {
+		d.routes[name] = route{name: name, notifier: n, retry: retryPolicy{retries: 2, backoff: time.Millisecond}}
+	}
+	return d
+}
+
+func TestDispatcherRoutesAlerts(t *testing.T) {
+	ops, mail := &fakeNotifier{}, &fakeNotifier{}
+	d := testDispatcher(map[string]*fakeNotifier{"ops": ops, "mail": mail}, map[string][]string{"a": {"mail"}})
+
+	d.dispatch(context.Background(), testAlert("a", down))
+	d.dispatch(context.Background(), testAlert("b", down))
+	d.wait()
+
+	if got := mail.received(); len(got) != 2 {
+		t.Errorf("Expected mail to get both alerts, but got %v", got)
+	}
+	if got := ops.received(); len(got) != 1 || got[0].target != "b" {
+		t.Errorf("Expected ops to only get the alert for b, but got %v", got)
+	}
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	flaky := &fakeNotifier{failures: 2}
+	broken := &fakeNotifier{failures: 100}
+	d := testDispatcher(map[string]*fakeNotifier{"flaky": flaky, "broken": broken}, map[string][]string{})
+
+	errs := make(chan error, 1)
+	d.errors = func(name string, a alert, err error) {
+		errs <- err
+	}
+
+	d.dispatch(context.Background(), testAlert("a", down))
+	d.wait()
+
+	if got := flaky.received(); len(got) != 1 || flaky.calls != 3 {
+		t.Errorf("Expected the alert after 3 attempts, but got %v after %v", got, flaky.calls)
+	}
+	if broken.calls != 3 {
+		t.Errorf("Expected 3 attempts, but got %v", broken.calls)
+	}
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "gave up after 3 attempts") {
+			t.Errorf("Expected the error to count the attempts, but got %v", err)
+		}
+	default:
+		t.Error("Expected the broken notifier to report an error")
+	}
+}
+
+func TestDispatcherStopsRetrying(t *testing.T) {
+	broken := &fakeNotifier{failures: 100}
+	d := testDispatcher(map[string]*fakeNotifier{"broken": broken}, map[string][]string{})
+	d.routes["broken"] = route{name: "broken", notifier: broken, retry: retryPolicy{retries: 2, backoff: time.Hour}}
+
+	errs := make(chan error, 1)
+	d.errors = func(name string, a alert, err error) {
+		errs <- err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.dispatch(ctx, testAlert("a", down))
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		d.wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected wait to return when the retries are stopped")
+	}
+	if broken.calls != 1 {
+		t.Errorf("Expected 1 attempt, but got %v", broken.calls)
+	}
+	if err := <-errs; !strings.Contains(err.Error(), "stopped after 1 attempts") {
+		t.Errorf("Expected the error to say the retries stopped, but got %v", err)
+	}
+}
+
+func TestDispatcherDeduplicates(t *testing.T) {
+	n := &fakeNotifier{}
+	d := testDispatcher(map[string]*fakeNotifier{"n": n}, map[string][]string{})
+	ctx := context.Background()
+
+	first := testAlert("a", down)
+	d.dispatch(ctx, first)
+	d.dispatch(ctx, testAlert("a", down))
+	// every change is sent, a second outage in the window too
+	d.dispatch(ctx, testAlert("a", up))
+	d.dispatch(ctx, testAlert("a", down))
+	d.dispatch(ctx, testAlert("a", down))
+	// other targets have their own last alert
+	d.dispatch(ctx, testAlert("b", down))
+
+	// the same alert after the window is sent again
+	later := testAlert("a", down)
+	later.result.at = first.result.at.Add(2 * time.Minute)
+	d.dispatch(ctx, later)
+	d.wait()
+
+	got := n.received()
+	states := []string{}
+	for _, a := range got {
+		states = append(states, a.target+" "+a.to.String())
+	}
+	if len(got) != 5 || strings.Count(strings.Join(states, ","), "a down") != 3 {
+		t.Errorf("Expected a down, up, down, b down and a down again, but got %v", states)
+	}
+}
+
+func TestNewDispatcher(t *testing.T) {
+	c, err := parseConfig([]byte(`
+targets:
+  - url: http://a
+    notify: [audit]
+  - url: http://b
+notifiers:
+  - name: audit
+    type: log
+    file: alerts.log
+    retries: 0
+  - name: ops
+    type: webhook
+    url: http://hooks
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := newDispatcher(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := d.routes["audit"]; r.retry.retries != 0 || r.retry.backoff != defaultBackoff {
+		t.Errorf("Expected no retries for audit, but got %+v", r.retry)
+	}
+	if r := d.routes["ops"]; r.retry.retries != defaultRetries {
+		t.Errorf("Expected the default retries for ops, but got %+v", r.retry)
+	}
+	if routes := d.routesFor("http://a"); len(routes) != 1 || routes[0].name != "audit" {
+		t.Errorf("Expected a to only notify audit, but got %v", routes)
+	}
+	if routes := d.routesFor("http://b"); len(routes) != 2 {
+		t.Errorf("Expected b to notify everyone, but got %v", routes)
+	}
+
+	bad := []string{
+		`[{name: x, type: pigeon}]`,
+		`[{name: x, type: webhook}]`,
+		`[{name: x, type: smtp, addr: "localhost:25"}]`,
+		`[{name: x, type: command}]`,
+		`[{name: x, type: log}]`,
+		`[{type: log, file: a.log}]`,
+		`[{name: x, type: log, file: a.log}, {name: x, type: log, file: b.log}]`,
+	}
+	for _, notifiers := range bad {
+		c, err := parseConfig([]byte("targets: [{url: http://a}]\nnotifiers: " + notifiers))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := newDispatcher(c); err == nil {
+			t.Errorf("Expected an error for %v, but got nil", notifiers)
+		}
+	}
+
+	c, _ = parseConfig([]byte("targets: [{url: http://a, notify: [nobody]}]"))
+	if _, err := newDispatcher(c); err == nil {
+		t.Error("Expected an error for an unknown notifier, but got nil")
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Probe checks a target once, ctx ends when the timeout of the target is over.
+// The probe only fills the state, code, latency and error of the result,
+// check fills in the rest
+type Probe interface {
+	Probe(ctx context.Context, t target) checkResult
+}
+
+// the scheme of the url picks the probe, a new probe only needs a type
+// that implements Probe and an entry here
+var probeSchemes = map[string]func(t target, client *http.Client) (Probe, error){
+	"http":  newHTTPProbe,
+	"https": newHTTPProbe,
+	"tcp":   newTCPProbe,
+	"dns":   newDNSProbe,
+	"tls":   newTLSProbe,
+	"port":  newPortProbe,
+}
+
+const defaultExpiryWarning = 14 * 24 * time.Hour
+
+// newProbe picks the probe for the target, the http client is shared by every http probe
+func newProbe(t target, client *http.Client) (Probe, error) {
+	u, err := url.Parse(t.URL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("target %q: invalid url %q", t.Name, t.URL)
+	}
+
+	build, ok := probeSchemes[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("target %q: unknown scheme %q", t.Name, u.Scheme)
+	}
+	p, err := build(t, client)
+	if err != nil {
+		return nil, fmt.Errorf("target %q: %w", t.Name, err)
+	}
+	return p, nil
+}
+
+// check runs the probe once, each check has its own timeout
+// and doesn't depend on the monitor context, so a check that already
+// started can finish when the monitor is stopping
+func check(p Probe, t target) checkResult {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), t.Timeout)
+	defer cancel()
+
+	r := p.Probe(ctx, t)
+	r.target, r.url, r.at = t.Name, t.URL, start
+	if r.latency == 0 {
+		r.latency = time.Since(start)
+	}
+	return r
+}
+
+func newHTTPProbe(t target, client *http.Client) (Probe, error) {
+	return &httpProbe{client: client}, nil
+}
+
+// hostPort reads host:port from the url, defaultPort is used when the url has none
+func hostPort(t target, defaultPort string) (string, error) {
+	u, _ := url.Parse(t.URL)
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+	}
+	if port == "" {
+		return "", fmt.Errorf("%v needs a port", t.URL)
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
+
+// tcpProbe is up when a tcp connection to tcp://host:port opens
+type tcpProbe struct {
+	addr string
+}
+
+func newTCPProbe(t target, client *http.Client) (Probe, error) {
+	addr, err := hostPort(t, "")
+	if err != nil {
+		return nil, err
+	}
+	return &tcpProbe{addr: addr}, nil
+}
+
+func (p *tcpProbe) Probe(ctx context.Context, t target) checkResult {
+	r := checkResult{}
+	start := time.Now()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	conn.Close()
+
+	r.latency = time.Since(start)
+	r.state, r.err = t.timely(r.latency)
+	return r
+}
+
+// dnsProbe resolves dns://name, with the resolver of the target when it has one,
+// the body assertions are checked against the addresses it resolves to
+type dnsProbe struct {
+	name     string
+	resolver *net.Resolver
+}
+
+func newDNSProbe(t target, client *http.Client) (Probe, error) {
+	u, _ := url.Parse(t.URL)
+	p := &dnsProbe{name: u.Hostname(), resolver: net.DefaultResolver}
+
+	if t.Resolver != "" {
+		addr := t.Resolver
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "53")
+		}
+		p.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network string, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		}
+	}
+	return p, nil
+}
+
+func (p *dnsProbe) Probe(ctx context.Context, t target) checkResult {
+	r := checkResult{}
+	start := time.Now()
+
+	addrs, err := p.resolver.LookupHost(ctx, p.name)
+	r.latency = time.Since(start)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	if len(addrs) == 0 {
+		r.err = fmt.Errorf("%v has no addresses", p.name)
+		return r
+	}
+
+	sort.Strings(addrs)
+	if r.err = t.matches(strings.Join(addrs, "\n")); r.err != nil {
+		return r
+	}
+	r.state, r.err = t.timely(r.latency)
+	return r
+}
+
+// tlsProbe does a tls handshake with tls://host:port and looks at the certificate,
+// it is down when the certificate is not valid and degraded when it expires soon
+type tlsProbe struct {
+	addr       string
+	serverName string
+	warning    time.Duration
+	// the system roots are used when nil
+	roots *x509.CertPool
+	now   func() time.Time
+}
+
+func newTLSProbe(t target, client *http.Client) (Probe, error) {
+	addr, err := hostPort(t, "443")
+	if err != nil {
+		return nil, err
+	}
+	u, _ := url.Parse(t.URL)
+
+	warning := t.ExpiryWarning
+	if warning == 0 {
+		warning = defaultExpiryWarning
+	}
+	return &tlsProbe{addr: addr, serverName: u.Hostname(), warning: warning, now: time.Now}, nil
+}
+
+func (p *tlsProbe) Probe(ctx context.Context, t target) checkResult {
+	r := checkResult{}
+	start := time.Now()
+
+	d := tls.Dialer{Config: &tls.Config{ServerName: p.serverName, RootCAs: p.roots}}
+	conn, err := d.DialContext(ctx, "tcp", p.addr)
+	r.latency = time.Since(start)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		r.err = fmt.Errorf("no certificate")
+		return r
+	}
+
+	// the handshake already failed if the certificate expired
+	left := certs[0].NotAfter.Sub(p.now())
+	if left < p.warning {
+		r.state = degraded
+		r.err = fmt.Errorf("certificate expires in %v, on %v", left.Round(time.Hour), certs[0].NotAfter.Format("2006-01-02"))
+		return r
+	}
+	r.state, r.err = t.timely(r.latency)
+	return r
+}
+
+// portProbe tries to open a tcp connection to each port of port://host,
+// it is up when every port is open, degraded when some are and down when none is
+type portProbe struct {
+	host  string
+	ports []int
+}
+
+func newPortProbe(t target, client *http.Client) (Probe, error) {
+	u, _ := url.Parse(t.URL)
+	ports := append([]int{}, t.Ports...)
+	if u.Port() != "" {
+		port, _ := strconv.Atoi(u.Port())
+		ports = append(ports, port)
+	}
+
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("%v needs ports", t.URL)
+	}
+	for _, port := range ports {
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %d", port)
+		}
+	}
+	sort.Ints(ports)
+
+	// a port in both the url and the list is only dialed once
+	unique := ports[:1]
+	for _, port := range ports[1:] {
+		if port != unique[len(unique)-1] {
+			unique = append(unique, port)
+		}
+	}
+	return &portProbe{host: u.Hostname(), ports: unique}, nil
+}
+
+func (p *portProbe) Probe(ctx context.Context, t target) checkResult {
+	r := checkResult{}
+	start := time.Now()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	closed := []int{}
+	for _, port := range p.ports {
+		wg.Add(1)
+		go func(port int) {
+			defer wg.Done()
+			var d net.Dialer
+			conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(p.host, strconv.Itoa(port)))
+			if err != nil {
+				mu.Lock()
+				closed = append(closed, port)
+				mu.Unlock()
+				return
+			}
+			conn.Close()
+		}(port)
+	}
+	wg.Wait()
+	sort.Ints(closed)
+
+	if len(closed) == 0 {
+		r.state, r.err = t.timely(time.Since(start))
+		return r
+	}
+	if len(closed) < len(p.ports) {
+		r.state = degraded
+	}
+	r.err = fmt.Errorf("ports %v are closed", closed)
+	return r
+}
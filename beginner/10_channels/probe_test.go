@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testProbe(t *testing.T, tt target) (Probe, target) {
+	c := config{Targets: []target{tt}}
+	c.setDefaults()
+	if err := c.validate(); err != nil {
+		t.Fatal(err)
+	}
+	p, err := newProbe(c.Targets[0], testClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, c.Targets[0]
+}
+
+func TestNewProbePicksTheScheme(t *testing.T) {
+	tests := map[string]Probe{
+		"http://example.com":     &httpProbe{},
+		"https://example.com":    &httpProbe{},
+		"tcp://example.com:5432": &tcpProbe{},
+		"dns://example.com":      &dnsProbe{},
+		"tls://example.com":      &tlsProbe{},
+		"port://example.com:22":  &portProbe{},
+	}
+
+	for url, want := range tests {
+		p, err := newProbe(target{URL: url}, nil)
+		if err != nil {
+			t.Errorf("Expected a probe for %v, but got %v", url, err)
+			continue
+		}
+		if fmt.Sprintf("%T", p) != fmt.Sprintf("%T", want) {
+			t.Errorf("Expected %T for %v, but got %T", want, url, p)
+		}
+	}
+
+	if p, _ := newProbe(target{URL: "tls://example.com"}, nil); p.(*tlsProbe).addr != "example.com:443" {
+		t.Errorf("Expected tls to use port 443 by default, but got %v", p.(*tlsProbe).addr)
+	}
+
+	for _, url := range []string{"ftp://example.com", "tcp://example.com", "port://example.com", "example.com"} {
+		if _, err := newProbe(target{URL: url}, nil); err == nil {
+			t.Errorf("Expected an error for %v, but got nil", url)
+		}
+	}
+	if _, err := newProbe(target{URL: "port://example.com", Ports: []int{70000}}, nil); err == nil {
+		t.Error("Expected an error for an invalid port, but got nil")
+	}
+}
+
+func TestHTTPProbeSendsTheRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Method != http.MethodPost || r.Header.Get("X-Token") != "secret" || r.Host != "status.example.com" || string(body) != `{"ping":true}` {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	p, tt := testProbe(t, target{
+		URL:         server.URL,
+		Method:      "post",
+		Headers:     map[string]string{"X-Token": "secret", "Host": "status.example.com"},
+		RequestBody: `{"ping":true}`,
+	})
+	if r := check(p, tt); r.state != up {
+		t.Errorf("Expected the request to be sent as configured, but got %v", r)
+	}
+}
+
+func listen(t *testing.T) (net.Listener, int) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return l, l.Addr().(*net.TCPAddr).Port
+}
+
+// closedPort returns a port nothing is listening on
+func closedPort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	return port
+}
+
+func TestTCPProbe(t *testing.T) {
+	l, _ := listen(t)
+	p, tt := testProbe(t, target{URL: "tcp://" + l.Addr().String()})
+	if r := check(p, tt); r.state != up || r.target != tt.Name || r.at.IsZero() {
+		t.Errorf("Expected the open port to be up, but got %+v", r)
+	}
+
+	p, tt = testProbe(t, target{URL: "tcp://127.0.0.1:" + strconv.Itoa(closedPort(t))})
+	if r := check(p, tt); r.state != down || r.err == nil {
+		t.Errorf("Expected the closed port to be down, but got %v", r)
+	}
+}
+
+func TestPortProbe(t *testing.T) {
+	_, a := listen(t)
+	_, b := listen(t)
+	closed := closedPort(t)
+
+	tests := []struct {
+		ports []int
+		want  state
+	}{
+		{[]int{a, b}, up},
+		{[]int{a, b, closed}, degraded},
+		{[]int{closed}, down},
+	}
+	for _, tt := range tests {
+		p, target := testProbe(t, target{URL: "port://127.0.0.1", Ports: tt.ports})
+		r := check(p, target)
+		if r.state != tt.want {
+			t.Errorf("Expected %v for ports %v, but got %v", tt.want, tt.ports, r)
+		}
+		if tt.want != up && !strings.Contains(r.err.Error(), strconv.Itoa(closed)) {
+			t.Errorf("Expected the error to name the closed port, but got %v", r.err)
+		}
+	}
+}
+
+func TestPortProbeDialsEachPortOnce(t *testing.T) {
+	closed := closedPort(t)
+	url := "port://127.0.0.1:" + strconv.Itoa(closed)
+	p, tt := testProbe(t, target{URL: url, Ports: []int{closed, closed}})
+
+	if ports := p.(*portProbe).ports; len(ports) != 1 || ports[0] != closed {
+		t.Errorf("Expected only port %v, but got %v", closed, ports)
+	}
+	if r := check(p, tt); r.err == nil || r.err.Error() != fmt.Sprintf("ports [%d] are closed", closed) {
+		t.Errorf("Expected the closed port once, but got %v", r.err)
+	}
+}
+
+func TestTLSProbe(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// the handshake the probe refuses would be logged
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	addr := server.Listener.Addr().String()
+
+	// the test certificate is valid for example.com
+	p := &tlsProbe{addr: addr, serverName: "example.com", warning: defaultExpiryWarning, roots: roots, now: time.Now}
+	tt := target{Name: "tls", Timeout: time.Second}
+	if r := check(p, tt); r.state != up {
+		t.Errorf("Expected a valid certificate to be up, but got %v", r)
+	}
+
+	// a week before the certificate expires
+	p.now = func() time.Time { return server.Certificate().NotAfter.Add(-7 * 24 * time.Hour) }
+	if r := check(p, tt); r.state != degraded || !strings.Contains(r.err.Error(), "expires in 168h") {
+		t.Errorf("Expected a certificate that expires soon to be degraded, but got %v", r)
+	}
+
+	// the system roots don't know the test certificate
+	p = &tlsProbe{addr: addr, serverName: "example.com", warning: defaultExpiryWarning, now: time.Now}
+	if r := check(p, tt); r.state != down || r.err == nil {
+		t.Errorf("Expected an unknown certificate to be down, but got %v", r)
+	}
+}
+
+// fakeDNS answers every A question with the addresses in records, and nothing else
+func fakeDNS(t *testing.T, records map[string]net.IP) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(dnsAnswer(buf[:n], records), addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func dnsAnswer(query []byte, records map[string]net.IP) []byte {
+	// the question starts after the 12 bytes of the header
+	labels := []string{}
+	i := 12
+	for query[i] != 0 {
+		n := int(query[i])
+		labels = append(labels, string(query[i+1:i+1+n]))
+		i += n + 1
+	}
+	question := query[12 : i+5]
+	qtype := binary.BigEndian.Uint16(query[i+1:])
+	name := strings.ToLower(strings.Join(labels, "."))
+
+	ip, ok := records[name]
+	rcode := byte(0)
+	if !ok {
+		// name error
+		rcode = 3
+	}
+	answers := 0
+	if ok && qtype == 1 {
+		answers = 1
+	}
+
+	msg := []byte{query[0], query[1], 0x81, 0x80 | rcode, 0, 1, 0, byte(answers), 0, 0, 0, 0}
+	msg = append(msg, question...)
+	if answers == 1 {
+		// a pointer to the name in the question, type A, class IN, a ttl of 60 and the address
+		msg = append(msg, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+		msg = append(msg, ip.To4()...)
+	}
+	return msg
+}
+
+func TestDNSProbe(t *testing.T) {
+	resolver := fakeDNS(t, map[string]net.IP{"status.example.com": net.IPv4(10, 0, 0, 7)})
+
+	p, tt := testProbe(t, target{URL: "dns://status.example.com", Resolver: resolver})
+	if r := check(p, tt); r.state != up {
+		t.Errorf("Expected the name to resolve, but got %v", r)
+	}
+
+	p, tt = testProbe(t, target{URL: "dns://status.example.com", Resolver: resolver, Body: "10.0.0.8"})
+	if r := check(p, tt); r.state != down || !strings.Contains(r.err.Error(), "10.0.0.8") {
+		t.Errorf("Expected the wrong address to be down, but got %v", r)
+	}
+
+	p, tt = testProbe(t, target{URL: "dns://missing.example.com", Resolver: resolver})
+	if r := check(p, tt); r.state != down || r.err == nil {
+		t.Errorf("Expected a missing name to be down, but got %v", r)
+	}
+}
+
+func TestCheckTimesOut(t *testing.T) {
+	p := probeFunc(func(ctx context.Context, t target) checkResult {
+		<-ctx.Done()
+		return checkResult{err: ctx.Err()}
+	})
+	if r := check(p, target{Timeout: 20 * time.Millisecond}); r.state != down || r.err != context.DeadlineExceeded {
+		t.Errorf("Expected the probe to time out, but got %v", r)
+	}
+}
+
+type probeFunc func(ctx context.Context, t target) checkResult
+
+func (f probeFunc) Probe(ctx context.Context, t target) checkResult {
+	return f(ctx, t)
+}
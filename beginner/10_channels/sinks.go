@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// alertPayload is how every sink writes an alert
+type alertPayload struct {
+	Target   string    `json:"target"`
+	URL      string    `json:"url"`
+	From     string    `json:"from"`
+	To       string    `json:"to"`
+	Flapping bool      `json:"flapping"`
+	Code     int       `json:"code,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	At       time.Time `json:"at"`
+	Message  string    `json:"message"`
+}
+
+func newAlertPayload(a alert) alertPayload {
+	p := alertPayload{
+		Target:   a.target,
+		URL:      a.result.url,
+		From:     a.from.String(),
+		To:       a.to.String(),
+		Flapping: a.flapping,
+		Code:     a.result.code,
+		At:       a.result.at,
+		Message:  a.String(),
+	}
+	if a.result.err != nil {
+		p.Error = a.result.err.Error()
+	}
+	return p
+}
+
+// webhookNotifier posts the alert as JSON
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhookNotifier(url string) *webhookNotifier {
+	c := clientConfig{Timeout: notifyTimeout}
+	c.setDefaults()
+	return &webhookNotifier{url: url, client: newClient(c)}
+}
+
+func (w *webhookNotifier) Notify(ctx context.Context, a alert) error {
+	bs, err := json.Marshal(newAlertPayload(a))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(bs))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxDrainSize))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %v", resp.Status)
+	}
+	return nil
+}
+
+// smtpNotifier sends an email for every alert
+type smtpNotifier struct {
+	addr     string
+	from     string
+	to       []string
+	username string
+	password string
+}
+
+func (s *smtpNotifier) Notify(ctx context.Context, a alert) error {
+	var auth smtp.Auth
+	if s.username != "" {
+		host, _, err := net.SplitHostPort(s.addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", s.username, s.password, host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %v\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %v\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&msg, "Subject: [monitor] %v is %v\r\n", a.target, a.to)
+	fmt.Fprintf(&msg, "Date: %v\r\n", a.result.at.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%v\r\n", a)
+
+	// SendMail has no context, it runs in a goroutine so the timeout still applies
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.addr, auth, s.from, s.to, msg.Bytes())
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// commandNotifier runs a command for every alert, the alert is written
+// as JSON on its stdin and the main fields are in the environment
+type commandNotifier struct {
+	command []string
+}
+
+func (c *commandNotifier) Notify(ctx context.Context, a alert) error {
+	p := newAlertPayload(a)
+	bs, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, c.command[0], c.command[1:]...)
+	cmd.Stdin = bytes.NewReader(bs)
+	cmd.Env = append(os.Environ(),
+		"MONITOR_TARGET="+p.Target,
+		"MONITOR_URL="+p.URL,
+		"MONITOR_FROM="+p.From,
+		"MONITOR_TO="+p.To,
+		fmt.Sprintf("MONITOR_FLAPPING=%v", p.Flapping),
+		"MONITOR_MESSAGE="+p.Message,
+	)
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %w: %s", c.command[0], err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// logNotifier appends every alert to a file, one JSON object per line
+type logNotifier struct {
+	filename string
+	mu       sync.Mutex
+}
+
+func (l *logNotifier) Notify(ctx context.Context, a alert) error {
+	bs, err := json.Marshal(newAlertPayload(a))
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(bs, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The store keeps every check on disk so the history survives a restart.
+// Checks are appended to the newest segment of a directory, one JSON object per line,
+// when a segment is full a new one is started and the oldest segments are deleted
+// when they are older than the retention or the segments take too much space.
+//
+//	data/
+//	  00000001.jsonl
+//	  00000002.jsonl
+//
+// A crash can only leave a partial line at the end of the newest segment,
+// it is cut off the next time the store is opened.
+
+const (
+	segmentExt            = ".jsonl"
+	defaultSegmentSize    = 8 << 20
+	defaultRetention      = 30 * 24 * time.Hour
+	defaultRetentionBytes = 256 << 20
+)
+
+// storeOptions limits the size of the store, a zero retention keeps everything
+type storeOptions struct {
+	segmentSize    int64
+	retention      time.Duration
+	retentionBytes int64
+	// now is time.Now, the tests replace it
+	now func() time.Time
+}
+
+// storedResult is how a check is written on disk
+type storedResult struct {
+	Target  string    `json:"target"`
+	URL     string    `json:"url,omitempty"`
+	State   string    `json:"state"`
+	Code    int       `json:"code,omitempty"`
+	Latency int64     `json:"latency_ns"`
+	Error   string    `json:"error,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+var states = map[string]state{"up": up, "degraded": degraded, "down": down}
+
+func newStoredResult(r checkResult) storedResult {
+	s := storedResult{
+		Target:  r.target,
+		URL:     r.url,
+		State:   r.state.String(),
+		Code:    r.code,
+		Latency: int64(r.latency),
+		At:      r.at,
+	}
+	if r.err != nil {
+		s.Error = r.err.Error()
+	}
+	return s
+}
+
+func (s storedResult) checkResult() checkResult {
+	r := checkResult{
+		target:  s.Target,
+		url:     s.URL,
+		state:   states[s.State],
+		code:    s.Code,
+		latency: time.Duration(s.Latency),
+		at:      s.At,
+	}
+	if s.Error != "" {
+		r.err = errors.New(s.Error)
+	}
+	return r
+}
+
+// segment is a single file of the store
+type segment struct {
+	seq  int
+	path string
+	size int64
+	// the time of the oldest and the newest check in the segment
+	first, last time.Time
+}
+
+type store struct {
+	dir  string
+	opts storeOptions
+
+	mu       sync.Mutex
+	segments []*segment
+	// the newest segment, the only one that is written to
+	active *os.File
+}
+
+// openStore opens the store in dir, creating it when it doesn't exist
+func openStore(dir string, opts storeOptions) (*store, error) {
+	if opts.segmentSize <= 0 {
+		opts.segmentSize = defaultSegmentSize
+	}
+	if opts.now == nil {
+		opts.now = time.Now
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &store{dir: dir, opts: opts}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.openActive(); err != nil {
+		return nil, err
+	}
+	// the retention may have passed while the monitor was stopped
+	if err := s.enforceRetention(); err != nil {
+		s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// load finds the segments and reads when each one starts and ends
+func (s *store) load() error {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.Atoi(strings.TrimSuffix(name, segmentExt))
+		if err != nil {
+			continue
+		}
+		s.segments = append(s.segments, &segment{seq: seq, path: filepath.Join(s.dir, name)})
+	}
+	sort.Slice(s.segments, func(i, j int) bool {
+		return s.segments[i].seq < s.segments[j].seq
+	})
+
+	for i, seg := range s.segments {
+		good, err := seg.scan(func(storedResult) bool { return true })
+		if err != nil {
+			return err
+		}
+		// only the newest segment was being written when the monitor stopped
+		if i == len(s.segments)-1 && good < seg.size {
+			if err := os.Truncate(seg.path, good); err != nil {
+				return err
+			}
+			seg.size = good
+		}
+	}
+	return nil
+}
+
+// scan reads every check of the segment until fn returns false, it updates the times and the size
+// of the segment and returns the offset after the last line that could be read
+func (seg *segment) scan(fn func(storedResult) bool) (int64, error) {
+	f, err := os.Open(seg.path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	seg.size = 0
+	seg.first, seg.last = time.Time{}, time.Time{}
+	good := int64(0)
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		seg.size += int64(len(line))
+		if err == io.EOF {
+			// a line without its newline was cut short
+			return good, nil
+		}
+		if err != nil {
+			return good, err
+		}
+
+		sr := storedResult{}
+		if json.Unmarshal(bytes.TrimSpace(line), &sr) != nil {
+			continue
+		}
+		good = seg.size
+		if seg.first.IsZero() || sr.At.Before(seg.first) {
+			seg.first = sr.At
+		}
+		if sr.At.After(seg.last) {
+			seg.last = sr.At
+		}
+		if !fn(sr) {
+			return good, nil
+		}
+	}
+}
+
+func (s *store) openActive() error {
+	if len(s.segments) == 0 || s.segments[len(s.segments)-1].size >= s.opts.segmentSize {
+		return s.rotate()
+	}
+
+	seg := s.segments[len(s.segments)-1]
+	f, err := os.OpenFile(seg.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	s.active = f
+	return nil
+}
+
+// rotate closes the active segment and starts the next one
+func (s *store) rotate() error {
+	if s.active != nil {
+		if err := s.active.Sync(); err != nil {
+			return err
+		}
+		if err := s.active.Close(); err != nil {
+			return err
+		}
+		s.active = nil
+	}
+
+	seq := 1
+	if len(s.segments) > 0 {
+		seq = s.segments[len(s.segments)-1].seq + 1
+	}
+	seg := &segment{seq: seq, path: filepath.Join(s.dir, fmt.Sprintf("%08d%v", seq, segmentExt))}
+
+	f, err := os.OpenFile(seg.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	s.active = f
+	s.segments = append(s.segments, seg)
+	return nil
+}
+
+// append writes a check at the end of the store
+func (s *store) append(r checkResult) error {
+	bs, err := json.Marshal(newStoredResult(r))
+	if err != nil {
+		return err
+	}
+	bs = append(bs, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil {
+		return fmt.Errorf("store is closed")
+	}
+
+	seg := s.segments[len(s.segments)-1]
+	if seg.size > 0 && seg.size+int64(len(bs)) > s.opts.segmentSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+		seg = s.segments[len(s.segments)-1]
+	}
+
+	if _, err := s.active.Write(bs); err != nil {
+		return err
+	}
+	seg.size += int64(len(bs))
+	if seg.first.IsZero() || r.at.Before(seg.first) {
+		seg.first = r.at
+	}
+	if r.at.After(seg.last) {
+		seg.last = r.at
+	}
+
+	return s.enforceRetention()
+}
+
+// enforceRetention deletes the oldest segments, the active one is never deleted
+func (s *store) enforceRetention() error {
+	total := int64(0)
+	for _, seg := range s.segments {
+		total += seg.size
+	}
+
+	cutoff := time.Time{}
+	if s.opts.retention > 0 {
+		cutoff = s.opts.now().Add(-s.opts.retention)
+	}
+
+	for len(s.segments) > 1 {
+		oldest := s.segments[0]
+		tooOld := !cutoff.IsZero() && oldest.last.Before(cutoff)
+		tooBig := s.opts.retentionBytes > 0 && total > s.opts.retentionBytes
+		if !tooOld && !tooBig {
+			break
+		}
+
+		if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= oldest.size
+		s.segments = s.segments[1:]
+	}
+	return nil
+}
+
+// replay calls fn with every check in the store, from the oldest to the newest
+func (s *store) replay(fn func(r checkResult)) error {
+	return s.query("", time.Time{}, time.Time{}, func(r checkResult) bool {
+		fn(r)
+		return true
+	})
+}
+
+// results returns the first limit checks of target between from and to, both included,
+// an empty target matches every target, a zero time or limit has no limit
+func (s *store) results(target string, from, to time.Time, limit int) ([]checkResult, error) {
+	results := []checkResult{}
+	err := s.query(target, from, to, func(r checkResult) bool {
+		results = append(results, r)
+		return limit <= 0 || len(results) < limit
+	})
+	return results, err
+}
+
+// query calls fn with the checks of target between from and to until fn returns false
+func (s *store) query(target string, from, to time.Time, fn func(r checkResult) bool) error {
+	// the segments are copied so the files are read without blocking append,
+	// the scans keep the sizes and times of the store as they are
+	s.mu.Lock()
+	segments := make([]segment, 0, len(s.segments))
+	for _, seg := range s.segments {
+		segments = append(segments, *seg)
+	}
+	s.mu.Unlock()
+
+	// whole segments are deleted, a segment that is kept can still have expired checks
+	if s.opts.retention > 0 {
+		if cutoff := s.opts.now().Add(-s.opts.retention); from.Before(cutoff) {
+			from = cutoff
+		}
+	}
+
+	for _, seg := range segments {
+		// segments outside of the range are not read
+		if (!from.IsZero() && seg.last.Before(from)) || (!to.IsZero() && seg.first.After(to)) {
+			continue
+		}
+
+		more := true
+		_, err := seg.scan(func(sr storedResult) bool {
+			if target != "" && sr.Target != target {
+				return true
+			}
+			if (!from.IsZero() && sr.At.Before(from)) || (!to.IsZero() && sr.At.After(to)) {
+				return true
+			}
+			more = fn(sr.checkResult())
+			return more
+		})
+		// the retention can delete a segment after it was copied
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+	}
+	return nil
+}
+
+// close flushes the active segment to disk
+func (s *store) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil {
+		return nil
+	}
+	err := s.active.Sync()
+	if cerr := s.active.Close(); err == nil {
+		err = cerr
+	}
+	s.active = nil
+	return err
+}
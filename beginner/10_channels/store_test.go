@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2021, 1, 2, 15, 4, 5, 0, time.UTC)
+
+func testStore(t *testing.T, dir string, opts storeOptions) *store {
+	s, err := openStore(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.close() })
+	return s
+}
+
+func appendCheck(t *testing.T, s *store, r checkResult) {
+	t.Helper()
+	if err := s.append(r); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	names, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestStoreKeepsChecksBetweenRuns(t *testing.T) {
+	dir := t.TempDir()
+	s := testStore(t, dir, storeOptions{})
+	appendCheck(t, s, checkResult{target: "a", url: "http://a", state: up, code: 200, latency: 20 * time.Millisecond, at: epoch})
+	appendCheck(t, s, checkResult{target: "a", url: "http://a", state: down, err: errors.New("refused"), at: epoch.Add(time.Minute)})
+	if err := s.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	h := newHistory(10)
+	if err := testStore(t, dir, storeOptions{}).replay(func(r checkResult) { h.record(r) }); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, ok := h.statsFor("a")
+	if !ok || stats.checks != 2 || stats.uptime != 50 || stats.state != down {
+		t.Fatalf("Expected a down with 50%% uptime over 2 checks, but got %v", stats)
+	}
+	if stats.last.err == nil || stats.last.err.Error() != "refused" || !stats.last.at.Equal(epoch.Add(time.Minute)) {
+		t.Errorf("Expected the error and time of the last check, but got %v at %v", stats.last.err, stats.last.at)
+	}
+	if stats.p50 != 20*time.Millisecond {
+		t.Errorf("Expected a p50 of 20ms, but got %v", stats.p50)
+	}
+}
+
+func TestStoreResultsBetweenTimes(t *testing.T) {
+	// small segments so the checks are spread over several files
+	s := testStore(t, t.TempDir(), storeOptions{segmentSize: 200})
+	for i := 0; i < 10; i++ {
+		for _, target := range []string{"a", "b"} {
+			appendCheck(t, s, checkResult{target: target, state: up, code: 200, at: epoch.Add(time.Duration(i) * time.Minute)})
+		}
+	}
+	if len(s.segments) < 3 {
+		t.Fatalf("Expected the checks in several segments, but got %d", len(s.segments))
+	}
+
+	results, err := s.results("a", epoch.Add(2*time.Minute), epoch.Add(5*time.Minute), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 checks of a, but got %d", len(results))
+	}
+	for i, r := range results {
+		if r.target != "a" || !r.at.Equal(epoch.Add(time.Duration(i+2)*time.Minute)) {
+			t.Errorf("Expected check %d of a at minute %d, but got %v at %v", i, i+2, r.target, r.at)
+		}
+	}
+
+	all, err := s.results("", time.Time{}, time.Time{}, 0)
+	if err != nil || len(all) != 20 {
+		t.Errorf("Expected every check without limits, but got %d: %v", len(all), err)
+	}
+
+	first, err := s.results("b", time.Time{}, time.Time{}, 3)
+	if err != nil || len(first) != 3 || !first[2].at.Equal(epoch.Add(2*time.Minute)) {
+		t.Errorf("Expected the first 3 checks of b, but got %v: %v", first, err)
+	}
+}
+
+func TestStoreRetention(t *testing.T) {
+	dir := t.TempDir()
+	now := epoch.Add(time.Hour)
+	s := testStore(t, dir, storeOptions{segmentSize: 200, retention: 30 * time.Minute, now: func() time.Time { return now }})
+
+	// an hour old, then new
+	for i := 0; i < 5; i++ {
+		appendCheck(t, s, checkResult{target: "a", state: up, at: epoch})
+	}
+	for i := 0; i < 5; i++ {
+		appendCheck(t, s, checkResult{target: "a", state: up, at: now})
+	}
+
+	results, _ := s.results("a", time.Time{}, time.Time{}, 0)
+	for _, r := range results {
+		if r.at.Before(now.Add(-30 * time.Minute)) {
+			t.Fatalf("Expected the old checks to be deleted, but got one at %v", r.at)
+		}
+	}
+	if len(results) == 0 {
+		t.Errorf("Expected the new checks to be kept")
+	}
+	if len(segmentFiles(t, dir)) != len(s.segments) {
+		t.Errorf("Expected %d files, but got %v", len(s.segments), segmentFiles(t, dir))
+	}
+}
+
+func TestStoreRetentionSize(t *testing.T) {
+	dir := t.TempDir()
+	s := testStore(t, dir, storeOptions{segmentSize: 200, retentionBytes: 500})
+	for i := 0; i < 50; i++ {
+		appendCheck(t, s, checkResult{target: "a", state: up, at: epoch.Add(time.Duration(i) * time.Second)})
+	}
+
+	size := int64(0)
+	for _, name := range segmentFiles(t, dir) {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size += info.Size()
+	}
+	if size > 500 {
+		t.Errorf("Expected at most 500 bytes on disk, but got %d", size)
+	}
+
+	results, _ := s.results("a", time.Time{}, time.Time{}, 0)
+	if len(results) == 0 || !results[len(results)-1].at.Equal(epoch.Add(49*time.Second)) {
+		t.Errorf("Expected the newest checks to be kept, but got %d checks", len(results))
+	}
+}
+
+func TestStoreCutsPartialLine(t *testing.T) {
+	dir := t.TempDir()
+	s := testStore(t, dir, storeOptions{})
+	appendCheck(t, s, checkResult{target: "a", state: up, at: epoch})
+	s.close()
+
+	// the monitor died in the middle of a write
+	f, err := os.OpenFile(segmentFiles(t, dir)[0], os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"target":"a","sta`)
+	f.Close()
+
+	s = testStore(t, dir, storeOptions{})
+	appendCheck(t, s, checkResult{target: "a", state: down, at: epoch.Add(time.Minute)})
+
+	results, err := s.results("a", time.Time{}, time.Time{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].state != up || results[1].state != down {
+		t.Errorf("Expected the partial line to be dropped, but got %v", results)
+	}
+}
+
+func TestDashboardHistory(t *testing.T) {
+	d := testDashboard()
+	d.store = testStore(t, t.TempDir(), storeOptions{})
+	for i := 0; i < 3; i++ {
+		appendCheck(t, d.store, checkResult{target: "a", state: up, code: 200, at: epoch.Add(time.Duration(i) * time.Hour)})
+	}
+	server := httptest.NewServer(d.handler())
+	defer server.Close()
+
+	resp, body := get(t, server, "/api/history?target=a&from=2021-01-02T16:00:00Z")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, but got %v: %v", resp.Status, body)
+	}
+	history := struct {
+		Checks []storedResult `json:"checks"`
+	}{}
+	if err := json.Unmarshal([]byte(body), &history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Checks) != 2 || history.Checks[0].State != "up" || !history.Checks[0].At.Equal(epoch.Add(time.Hour)) {
+		t.Errorf("Expected the last 2 checks of a, but got %+v", history.Checks)
+	}
+
+	// a page ends with the time of the first check left
+	resp, body = get(t, server, "/api/history?target=a&limit=2")
+	page := struct {
+		Checks []storedResult `json:"checks"`
+		Next   time.Time      `json:"next"`
+	}{}
+	if err := json.Unmarshal([]byte(body), &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Checks) != 2 || !page.Next.Equal(epoch.Add(2*time.Hour)) {
+		t.Errorf("Expected 2 checks and the next one at %v, but got %d and %v", epoch.Add(2*time.Hour), len(page.Checks), page.Next)
+	}
+
+	for _, path := range []string{"/api/history", "/api/history?target=a&to=yesterday", "/api/history?target=a&limit=0"} {
+		if resp, _ := get(t, server, path); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %v, but got %v", path, resp.Status)
+		}
+	}
+
+	// without a store there is no history
+	server = httptest.NewServer(testDashboard().handler())
+	defer server.Close()
+	if resp, _ := get(t, server, "/api/history?target=a"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 without a store, but got %v", resp.Status)
+	}
+}
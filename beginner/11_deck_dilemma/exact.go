@@ -0,0 +1,70 @@
+package main
+
+import (
+	"math"
+	"math/big"
+)
+
+// the z score of a 95% confidence interval
+const z95 = 1.96
+
+// exactProbability enumerates every outcome of the riddle, the hidden card,
+// the answer it gives and every guess among the cards with that answer,
+// and adds up the probability of the outcomes where the guess is right
+func exactProbability(d deck, q question) *big.Rat {
+	total := new(big.Rat)
+	pick := big.NewRat(1, int64(len(d)))
+
+	for _, hidden := range d {
+		answer := q.ask(hidden)
+		candidates := Filter(d, func(c card) bool {
+			return q.ask(c) == answer
+		})
+
+		guessOdds := new(big.Rat).Mul(pick, big.NewRat(1, int64(len(candidates))))
+		for _, guess := range candidates {
+			if guess == hidden {
+				total.Add(total, guessOdds)
+			}
+		}
+	}
+	return total
+}
+
+// comparison puts the estimate of the simulation next to the exact value
+type comparison struct {
+	exact     *big.Rat
+	estimate  float64
+	absError  float64
+	low, high float64
+	// the exact value is not inside the confidence interval of the estimate
+	outside bool
+}
+
+func compare(r result, exact *big.Rat) comparison {
+	e, _ := exact.Float64()
+	low, high := wilsonInterval(r.successes, r.trials)
+	return comparison{
+		exact:    exact,
+		estimate: r.probability(),
+		absError: math.Abs(r.probability() - e),
+		low:      low,
+		high:     high,
+		outside:  e < low || e > high,
+	}
+}
+
+// wilsonInterval is the 95% confidence interval of a binomial proportion,
+// it behaves better than the normal approximation for small probabilities
+func wilsonInterval(successes int, trials int) (float64, float64) {
+	if trials == 0 {
+		return 0, 1
+	}
+
+	n := float64(trials)
+	p := float64(successes) / n
+	z2 := z95 * z95
+	center := (p + z2/(2*n)) / (1 + z2/n)
+	margin := z95 / (1 + z2/n) * math.Sqrt(p*(1-p)/n+z2/(4*n*n))
+	return math.Max(center-margin, 0), math.Min(center+margin, 1)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestExactProbability(t *testing.T) {
+	d := newDeck()
+	for _, q := range questions {
+		if p := exactProbability(d, q); p.Cmp(big.NewRat(1, 26)) != 0 {
+			t.Errorf("Expected 1/26 for %v, but got %v", q.name, p.RatString())
+		}
+	}
+
+	// a question that is always true tells us nothing
+	always := question{"always", func(card) bool { return true }}
+	if p := exactProbability(d, always); p.Cmp(big.NewRat(1, 52)) != 0 {
+		t.Errorf("Expected 1/52 for %v, but got %v", always.name, p.RatString())
+	}
+}
+
+func TestWilsonInterval(t *testing.T) {
+	low, high := wilsonInterval(385, 10000)
+	if low > 0.0385 || high < 0.0385 || high-low > 0.01 {
+		t.Errorf("Expected a narrow interval around 0.0385, but got [%v, %v]", low, high)
+	}
+
+	low, high = wilsonInterval(0, 100)
+	if low != 0 || high <= 0 {
+		t.Errorf("Expected an interval starting at 0, but got [%v, %v]", low, high)
+	}
+}
+
+func TestCompareFlagsEstimatesOutsideTheInterval(t *testing.T) {
+	exact := big.NewRat(1, 26)
+
+	if c := compare(result{trials: 100000, successes: 3846}, exact); c.outside {
+		t.Errorf("Expected the estimate to agree with 1/26, but got %+v", c)
+	}
+
+	if c := compare(result{trials: 100000, successes: 5000}, exact); !c.outside {
+		t.Errorf("Expected the estimate to be flagged, but got %+v", c)
+	}
+}
@@ -0,0 +1,224 @@
+// Package fraction finds the best rational approximation of a float
+// using continued fractions.
+package fraction
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// maxTerms caps the number of continued fraction terms that are computed,
+// a float64 never needs more than this for a denominator that fits an int64
+const maxTerms = 1500
+
+var (
+	ErrNotFinite    = errors.New("fraction: value is NaN or infinite")
+	ErrDenominator  = errors.New("fraction: maximum denominator must be at least 1")
+	ErrTolerance    = errors.New("fraction: tolerance must be a non negative number")
+	ErrOverflow     = errors.New("fraction: result does not fit an int64")
+	ErrTooManyTerms = errors.New("fraction: too many continued fraction terms")
+	ErrZeroDivision = errors.New("fraction: denominator is zero")
+)
+
+// Fraction is a reduced fraction, Den is always positive
+type Fraction struct {
+	Num int64
+	Den int64
+}
+
+// New returns num/den reduced to lowest terms.
+func New(num int64, den int64) (Fraction, error) {
+	if den == 0 {
+		return Fraction{}, ErrZeroDivision
+	}
+	return fromRat(big.NewRat(num, den))
+}
+
+func fromRat(r *big.Rat) (Fraction, error) {
+	if !r.Num().IsInt64() || !r.Denom().IsInt64() {
+		return Fraction{}, ErrOverflow
+	}
+	return Fraction{Num: r.Num().Int64(), Den: r.Denom().Int64()}, nil
+}
+
+// Rat returns the fraction as a big.Rat.
+func (f Fraction) Rat() *big.Rat {
+	return big.NewRat(f.Num, f.Den)
+}
+
+func (f Fraction) Float64() float64 {
+	v, _ := f.Rat().Float64()
+	return v
+}
+
+// String formats the fraction as "n/d", or "n" when it is a whole number.
+func (f Fraction) String() string {
+	if f.Den == 1 {
+		return fmt.Sprint(f.Num)
+	}
+	return fmt.Sprintf("%d/%d", f.Num, f.Den)
+}
+
+// Mixed formats the fraction as a mixed number like "2 1/3" or "-1 1/2".
+func (f Fraction) Mixed() string {
+	whole := f.Num / f.Den
+	rest := f.Num % f.Den
+	if rest == 0 {
+		return fmt.Sprint(whole)
+	}
+	if whole == 0 {
+		return f.String()
+	}
+	if rest < 0 {
+		rest = -rest
+	}
+	return fmt.Sprintf("%d %d/%d", whole, rest, f.Den)
+}
+
+// Approximate returns the fraction closest to x whose denominator is at
+// most maxDen. The closest fraction is either the last convergent of x that fits
+// or the best semiconvergent after it, when both are as close the convergent is
+// returned, its denominator is never larger. Between two whole numbers, like 2 and 3
+// for 2.5, the convergent is the one nearer to zero.
+func Approximate(x float64, maxDen int64) (Fraction, error) {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return Fraction{}, ErrNotFinite
+	}
+	if maxDen < 1 {
+		return Fraction{}, ErrDenominator
+	}
+	// the convergents of a negative number are below it,
+	// so ties would round away from zero instead of towards it
+	if x < 0 {
+		f, err := Approximate(-x, maxDen)
+		return Fraction{Num: -f.Num, Den: f.Den}, err
+	}
+
+	exact := new(big.Rat).SetFloat64(x)
+	if exact.Denom().Cmp(big.NewInt(maxDen)) <= 0 {
+		return fromRat(exact)
+	}
+
+	// p0/q0 and p1/q1 are the last two convergents
+	p0, q0 := big.NewInt(0), big.NewInt(1)
+	p1, q1 := big.NewInt(1), big.NewInt(0)
+	n := new(big.Int).Set(exact.Num())
+	d := new(big.Int).Set(exact.Denom())
+	max := big.NewInt(maxDen)
+
+	a, m := new(big.Int), new(big.Int)
+	for terms := 0; ; terms++ {
+		if terms == maxTerms {
+			return Fraction{}, ErrTooManyTerms
+		}
+
+		// the next term and what is left of the number
+		a.DivMod(n, d, m)
+		q2 := new(big.Int).Add(q0, new(big.Int).Mul(a, q1))
+		if q2.Cmp(max) > 0 {
+			break
+		}
+
+		p2 := new(big.Int).Add(p0, new(big.Int).Mul(a, p1))
+		p0, q0, p1, q1 = p1, q1, p2, q2
+		n, d = d, new(big.Int).Set(m)
+	}
+
+	// the best semiconvergent below the maximum denominator
+	k := new(big.Int).Div(new(big.Int).Sub(max, q0), q1)
+	semi := new(big.Rat).SetFrac(
+		new(big.Int).Add(p0, new(big.Int).Mul(k, p1)),
+		new(big.Int).Add(q0, new(big.Int).Mul(k, q1)),
+	)
+	conv := new(big.Rat).SetFrac(p1, q1)
+
+	if distance(conv, exact).Cmp(distance(semi, exact)) <= 0 {
+		return fromRat(conv)
+	}
+	return fromRat(semi)
+}
+
+// WithTolerance returns the fraction with the smallest denominator
+// that is at most tol away from x.
+func WithTolerance(x float64, tol float64) (Fraction, error) {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return Fraction{}, ErrNotFinite
+	}
+	if math.IsNaN(tol) || math.IsInf(tol, 0) || tol < 0 {
+		return Fraction{}, ErrTolerance
+	}
+
+	exact := new(big.Rat).SetFloat64(x)
+	t := new(big.Rat).SetFloat64(tol)
+	lo := new(big.Rat).Sub(exact, t)
+	hi := new(big.Rat).Add(exact, t)
+
+	r, err := simplestBetween(lo, hi)
+	if err != nil {
+		return Fraction{}, err
+	}
+	return fromRat(r)
+}
+
+// simplestBetween finds the fraction with the smallest denominator in [lo, hi]
+func simplestBetween(lo *big.Rat, hi *big.Rat) (*big.Rat, error) {
+	if lo.Sign() <= 0 && hi.Sign() >= 0 {
+		return new(big.Rat), nil
+	}
+
+	// solve the negative interval as the positive one and flip the sign
+	if hi.Sign() < 0 {
+		r, err := simplestBetween(new(big.Rat).Neg(hi), new(big.Rat).Neg(lo))
+		if err != nil {
+			return nil, err
+		}
+		return r.Neg(r), nil
+	}
+
+	// the continued fraction of the answer is the common prefix of the
+	// continued fractions of lo and hi, plus the smallest term that fits
+	terms := []*big.Int{}
+	lo, hi = new(big.Rat).Set(lo), new(big.Rat).Set(hi)
+	for {
+		if len(terms) == maxTerms {
+			return nil, ErrTooManyTerms
+		}
+
+		fl := floor(lo)
+		if new(big.Rat).SetInt(fl).Cmp(lo) == 0 {
+			terms = append(terms, fl)
+			break
+		}
+
+		next := new(big.Int).Add(fl, big.NewInt(1))
+		if new(big.Rat).SetInt(next).Cmp(hi) <= 0 {
+			terms = append(terms, next)
+			break
+		}
+
+		// both ends are between fl and fl+1, continue with 1/(x-fl)
+		// which swaps the ends of the interval
+		terms = append(terms, fl)
+		flr := new(big.Rat).SetInt(fl)
+		lo, hi = new(big.Rat).Inv(new(big.Rat).Sub(hi, flr)), new(big.Rat).Inv(new(big.Rat).Sub(lo, flr))
+	}
+
+	r := new(big.Rat).SetInt(terms[len(terms)-1])
+	for i := len(terms) - 2; i >= 0; i-- {
+		r.Inv(r)
+		r.Add(r, new(big.Rat).SetInt(terms[i]))
+	}
+	return r, nil
+}
+
+func floor(r *big.Rat) *big.Int {
+	q, m := new(big.Int), new(big.Int)
+	q.DivMod(r.Num(), r.Denom(), m)
+	return q
+}
+
+func distance(a *big.Rat, b *big.Rat) *big.Rat {
+	return new(big.Rat).Abs(new(big.Rat).Sub(a, b))
+}
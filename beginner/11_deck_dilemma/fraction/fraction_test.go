@@ -0,0 +1,186 @@
+package fraction
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+	"testing/quick"
+)
+
+var config = &quick.Config{MaxCount: 2000, Rand: rand.New(rand.NewSource(1))}
+
+// a fraction made from any p/q with q <= maxDen is recovered exactly
+func TestApproximateRecoversFractions(t *testing.T) {
+	property := func(p int32, q uint16) bool {
+		den := int64(q) + 1
+		expected := big.NewRat(int64(p), den)
+		x, _ := expected.Float64()
+
+		f, err := Approximate(x, 1<<16)
+		return err == nil && f.Rat().Cmp(expected) == 0
+	}
+
+	if err := quick.Check(property, config); err != nil {
+		t.Error(err)
+	}
+}
+
+// no fraction with a denominator up to maxDen is closer to x
+func TestApproximateIsBest(t *testing.T) {
+	property := func(x float64, maxDen uint8) bool {
+		x = math.Mod(x, 1000)
+		max := int64(maxDen)%60 + 1
+		f, err := Approximate(x, max)
+		if err != nil || f.Den > max {
+			return false
+		}
+
+		exact := new(big.Rat).SetFloat64(x)
+		best := distance(f.Rat(), exact)
+		for q := int64(1); q <= max; q++ {
+			p := int64(math.Round(x * float64(q)))
+			for _, candidate := range []int64{p - 1, p, p + 1} {
+				if distance(big.NewRat(candidate, q), exact).Cmp(best) < 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	if err := quick.Check(property, config); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestApproximateIsSymmetric(t *testing.T) {
+	property := func(x float64, maxDen uint32) bool {
+		max := int64(maxDen) + 1
+		a, err1 := Approximate(x, max)
+		b, err2 := Approximate(-x, max)
+		return err1 == err2 && a.Num == -b.Num && a.Den == b.Den
+	}
+
+	if err := quick.Check(property, config); err != nil {
+		t.Error(err)
+	}
+}
+
+// the result is within the tolerance and no smaller denominator is
+func TestWithTolerance(t *testing.T) {
+	property := func(x float64, tol uint16) bool {
+		x = math.Mod(x, 100)
+		tolerance := (float64(tol) + 1) / 1e5
+		f, err := WithTolerance(x, tolerance)
+		if err != nil {
+			return false
+		}
+
+		exact := new(big.Rat).SetFloat64(x)
+		limit := new(big.Rat).SetFloat64(tolerance)
+		if distance(f.Rat(), exact).Cmp(limit) > 0 {
+			return false
+		}
+
+		for q := int64(1); q < f.Den; q++ {
+			p := int64(math.Round(x * float64(q)))
+			for _, candidate := range []int64{p - 1, p, p + 1} {
+				if distance(big.NewRat(candidate, q), exact).Cmp(limit) <= 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	if err := quick.Check(property, config); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestKnownValues(t *testing.T) {
+	tests := []struct {
+		x      float64
+		maxDen int64
+		result string
+	}{
+		{math.Pi, 10, "22/7"},
+		{math.Pi, 1000, "355/113"},
+		{-math.Pi, 1000, "-355/113"},
+		{1.0 / 26, 1000, "1/26"},
+		{0.0385, 30, "1/26"},
+		{2.5, 10, "5/2"},
+		{0, 10, "0"},
+		{7, 1, "7"},
+		{0.999999, 100, "1"},
+		// ties between the convergent and the semiconvergent
+		{0.25, 2, "0"},
+		{0.875, 4, "1"},
+		{2.5, 1, "2"},
+		{-2.5, 1, "-2"},
+	}
+
+	for _, test := range tests {
+		f, err := Approximate(test.x, test.maxDen)
+		if err != nil || f.String() != test.result {
+			t.Errorf("Expected %v for %v, but got %v and %v", test.result, test.x, f, err)
+		}
+	}
+
+	f, _ := WithTolerance(0.0386, 0.0005)
+	if f.String() != "1/26" {
+		t.Errorf("Expected 1/26, but got %v", f)
+	}
+
+	// 1/25 is inside the wider interval and has a smaller denominator
+	f, _ = WithTolerance(0.0389, 0.0012)
+	if f.String() != "1/25" {
+		t.Errorf("Expected 1/25, but got %v", f)
+	}
+}
+
+func TestMixed(t *testing.T) {
+	tests := map[Fraction]string{
+		{Num: 7, Den: 3}:   "2 1/3",
+		{Num: -3, Den: 2}:  "-1 1/2",
+		{Num: 3, Den: 4}:   "3/4",
+		{Num: -3, Den: 4}:  "-3/4",
+		{Num: 4, Den: 1}:   "4",
+		{Num: -10, Den: 1}: "-10",
+	}
+
+	for f, expected := range tests {
+		if got := f.Mixed(); got != expected {
+			t.Errorf("Expected %v for %v, but got %v", expected, f, got)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if _, err := Approximate(math.NaN(), 10); !errors.Is(err, ErrNotFinite) {
+		t.Errorf("Expected %v, but got %v", ErrNotFinite, err)
+	}
+
+	if _, err := Approximate(0.5, 0); !errors.Is(err, ErrDenominator) {
+		t.Errorf("Expected %v, but got %v", ErrDenominator, err)
+	}
+
+	if _, err := WithTolerance(0.5, -1); !errors.Is(err, ErrTolerance) {
+		t.Errorf("Expected %v, but got %v", ErrTolerance, err)
+	}
+
+	if _, err := Approximate(1e30, 10); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Expected %v, but got %v", ErrOverflow, err)
+	}
+
+	if _, err := New(1, 0); !errors.Is(err, ErrZeroDivision) {
+		t.Errorf("Expected %v, but got %v", ErrZeroDivision, err)
+	}
+
+	f, _ := New(6, -4)
+	if f.Num != -3 || f.Den != 2 {
+		t.Errorf("Expected -3/2, but got %v", f)
+	}
+}
@@ -12,15 +12,22 @@ package main
 // Your friend will answer truthfully.
 // Which question would you ask that gives you the best chance of guessing the correct card?
 //
-// This program simulates that interaction 100.000 times for each question to prove that
-// the probability for guessing the correct card is the same, doesn't matter what question you ask.
+// This program simulates that interaction -trials times (100.000 by default) for each question,
+// the ones of the riddle, a few more and any added with -query, to prove that the probability
+// for guessing the correct card is the same, doesn't matter what question you ask.
 // You could calculate this manually using mathematical probability, but I found this method much more fun.
 // And it helps you stop doubting yourself since the results are counter-intuitive.
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"math"
+	"io"
 	"math/rand"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
 	"time"
 )
 
@@ -31,125 +38,96 @@ type card struct {
 
 type deck []card
 
-type fraction struct {
-	n int
-	d int
+// questions asked in the simulation, new questions only need a name and a predicate
+var questions = []question{
+	{"isRed", card.isRed},
+	{"isFace", card.isFace},
+	{"isAce", card.isAce},
+	{"isHeart", card.isHeart},
+	{"isAboveSeven", func(c card) bool { return c.rank() > 7 }},
+	// compound questions combine the predicates
+	{"isRedFace", And(card.isRed, card.isFace)},
 }
 
 func main() {
-	ch := make(chan string)
-	d := newDeck()
-
-	guessesTotal := 300000
-	guessesEach := guessesTotal / 3
-
-	count := map[string]int{
-		"isRed":  0,
-		"isFace": 0,
-		"isAce":  0,
-	}
-
-	for i := 0; i < guessesEach; i++ {
-		go guessByIsRed(d, ch)
-	}
-
-	for i := 0; i < guessesEach; i++ {
-		go guessByIsFace(d, ch)
+	guessesEach := flag.Int("trials", 100000, "number of guesses for each question")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of goroutines running the guesses")
+	progress := flag.Bool("progress", true, "report the progress on stderr")
+	seed := flag.Int64("seed", 0, "master seed to reproduce a run, a random one is picked when 0")
+	output := flag.String("output", "text", "report format, one of "+strings.Join(reportFormats(), ", "))
+	optimizeOnly := flag.Bool("optimize", false, "rank the questions and the strategies to ask them instead of simulating")
+	depth := flag.Int("depth", 2, fmt.Sprintf("number of questions a strategy may ask when optimizing, at most %d", maxDepth))
+	queries := queryFlags{}
+	flag.Var(&queries, "query", `extra question like "red && !face", can be repeated`)
+	flag.Parse()
+
+	qs := append(append([]question{}, questions...), queries...)
+
+	if *optimizeOnly {
+		o, err := optimize(newDeck(), qs, *depth)
+		if err == nil {
+			err = o.write(os.Stdout)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(2)
+		}
+		return
 	}
 
-	for i := 0; i < guessesEach; i++ {
-		go guessByIsAce(d, ch)
+	write, ok := reportWriters[*output]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown output %q\n", *output)
+		os.Exit(2)
 	}
 
-	for i := 0; i < guessesTotal; i++ {
-		key := <-ch
-		if v, ok := count[key]; ok {
-			count[key] = v + 1
-		}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
 	}
 
-	isRedProb := float64(count["isRed"]) / float64(guessesEach)
-	isFaceProb := float64(count["isFace"]) / float64(guessesEach)
-	isAceProb := float64(count["isAce"]) / float64(guessesEach)
-	isRedFraction := roundToFraction(isRedProb)
-	isFaceFraction := roundToFraction(isFaceProb)
-	isAceFraction := roundToFraction(isAceProb)
-	fmt.Println("probabilities:")
-	fmt.Printf("  isRed:  %.4f ~ %d/%d \n", isRedProb, isRedFraction.n, isRedFraction.d)
-	fmt.Printf("  isFace: %.4f ~ %d/%d \n", isFaceProb, isFaceFraction.n, isFaceFraction.d)
-	fmt.Printf("  isAce:  %.4f ~ %d/%d \n", isAceProb, isAceFraction.n, isAceFraction.d)
-	fmt.Printf("expected: %.4f ~ 1/26 \n", 1.0/26.0)
-}
+	// ctrl-c stops the workers and we print what was done so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-func roundToFraction(x float64) fraction {
-	precision := 0.000001
-	n := math.Floor(x)
-	x = x - n
-	if x < precision {
-		return fraction{n: int(n), d: 1}
-	} else if 1-precision < x {
-		return fraction{n: int(n + 1), d: 1}
+	if *guessesEach <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: -trials must be greater than 0")
+		os.Exit(2)
 	}
-
-	lower := fraction{n: 0, d: 1}
-	upper := fraction{n: 1, d: 1}
-
-	for {
-		middle := fraction{
-			n: lower.n + upper.n,
-			d: lower.d + upper.d,
-		}
-
-		if float64(middle.d)*(x-precision) < float64(middle.n) {
-			upper.n = middle.n
-			upper.d = middle.d
-		} else if float64(middle.d)*(x-precision) > float64(middle.n) {
-			lower.n = middle.n
-			lower.d = middle.d
-		} else {
-			return fraction{
-				n: 1, d: middle.d / (int(n)*middle.d + middle.n),
-			}
-		}
+	if *workers <= 0 {
+		*workers = runtime.NumCPU()
 	}
-}
-
-func guessByIsRed(d deck, ch chan string) {
-	c := d.random()
-	if c.isRed() {
-		d = d.reds()
-	} else {
-		d = d.blacks()
+	cfg := simConfig{trials: *guessesEach, workers: *workers, seed: *seed}
+	if *progress {
+		cfg.progress = printProgress(os.Stderr)
 	}
-	sendIfTrue(ch, "isRed", d.random() == c)
-}
 
-func guessByIsFace(d deck, ch chan string) {
-	c := d.random()
-	if c.isFace() {
-		d = d.faces()
-	} else {
-		d = d.nonFaces()
+	d := newDeck()
+	start := time.Now()
+	results, err := simulate(ctx, d, qs, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Error:", err)
 	}
-	sendIfTrue(ch, "isFace", d.random() == c)
-}
 
-func guessByIsAce(d deck, ch chan string) {
-	c := d.random()
-	if c.isAce() {
-		d = d.aces()
-	} else {
-		d = d.nonAces()
+	r := newReport(d, qs, results, cfg, time.Since(start), err != nil)
+	if err := write(r, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
 	}
-	sendIfTrue(ch, "isAce", d.random() == c)
 }
 
-func sendIfTrue(ch chan string, s string, b bool) {
-	if b {
-		ch <- s
-		return
+// printProgress returns a progress callback that rewrites a single line of w
+func printProgress(w io.Writer) func(done int, total int) {
+	return func(done int, total int) {
+		// nothing to report when there are no guesses
+		if total == 0 {
+			return
+		}
+		fmt.Fprintf(w, "\r%3d%% %d/%d", done*100/total, done, total)
+		if done == total {
+			fmt.Fprintln(w)
+		}
 	}
-	ch <- ""
 }
 
 func (c card) isAce() bool {
@@ -164,70 +142,24 @@ func (c card) isRed() bool {
 	return c.suit == "Diamonds" || c.suit == "Hearts"
 }
 
-func (d deck) reds() deck {
-	cards := deck{}
-	for _, c := range d {
-		if c.isRed() {
-			cards = append(cards, c)
-		}
-	}
-	return cards
-}
-
-func (d deck) blacks() deck {
-	cards := deck{}
-	for _, c := range d {
-		if !c.isRed() {
-			cards = append(cards, c)
-		}
-	}
-	return cards
+func (c card) isHeart() bool {
+	return c.suit == "Hearts"
 }
 
-func (d deck) aces() deck {
-	cards := deck{}
-	for _, c := range d {
-		if c.isAce() {
-			cards = append(cards, c)
-		}
-	}
-	return cards
+// rank is the value as a number, 1 for the Ace up to 13 for the King
+func (c card) rank() int {
+	return ranks[c.value]
 }
 
-func (d deck) nonAces() deck {
-	cards := deck{}
-	for _, c := range d {
-		if !c.isAce() {
-			cards = append(cards, c)
-		}
-	}
-	return cards
-}
-
-func (d deck) faces() deck {
-	cards := deck{}
-	for _, c := range d {
-		if c.isFace() {
-			cards = append(cards, c)
-		}
-	}
-	return cards
-}
-
-func (d deck) nonFaces() deck {
-	cards := deck{}
-	for _, c := range d {
-		if !c.isFace() {
-			cards = append(cards, c)
-		}
-	}
-	return cards
+// random takes the source from the caller, each worker has its own
+// so they don't fight over the global one
+func (d deck) random(r *rand.Rand) card {
+	return d[r.Intn(len(d))]
 }
 
-func (d deck) random() card {
-	rand.Seed(time.Now().UnixNano())
-	r := rand.Intn(len(d))
-	return d[r]
+var ranks = map[string]int{
+	"Ace": 1, "Two": 2, "Three": 3, "Four": 4, "Five": 5, "Six": 6, "Seven": 7,
+	"Eight": 8, "Nine": 9, "Ten": 10, "Jack": 11, "Queen": 12, "King": 13,
 }
 
 func newDeck() deck {
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// maxDepth limits the questions of a strategy, the search grows
+// with the number of questions to the power of the depth
+const maxDepth = 3
+
+// The riddle has the same answer for every question because a single yes or no
+// splits the deck in two and we always guess one card from the half we are left with,
+// so the chance of winning is 2/52 no matter where the split is.
+// What changes from one question to another is how much the answer tells us,
+// the optimizer measures that and searches for the best way to ask several questions.
+
+// questionStats measures a question before we ask it
+type questionStats struct {
+	name string
+	// successes out of len(d), the chance of guessing the card after hearing the answer
+	successes int
+	cards     int
+	// gain is how many bits of uncertainty about the card the answer removes,
+	// it is the entropy of the answer, 1 for an answer as likely to be yes as no
+	gain float64
+}
+
+func (s questionStats) success() float64 {
+	return float64(s.successes) / float64(s.cards)
+}
+
+// strategy is a plan for asking questions one after the other,
+// the next question depends on the answer to the previous one
+// and a nil branch means we stop asking and guess
+type strategy struct {
+	q   question
+	yes *strategy
+	no  *strategy
+}
+
+// String describes the plan, "isRed(yes: isFace, no: isAce)" asks isRed
+// and then isFace or isAce depending on the answer
+func (s *strategy) String() string {
+	if s.yes == nil && s.no == nil {
+		return s.q.name
+	}
+
+	branches := []string{}
+	if s.yes != nil {
+		branches = append(branches, "yes: "+s.yes.String())
+	}
+	if s.no != nil {
+		branches = append(branches, "no: "+s.no.String())
+	}
+	return s.q.name + "(" + strings.Join(branches, ", ") + ")"
+}
+
+// cells returns the groups of cards that can't be told apart after following the plan,
+// empty groups are left out
+func (s *strategy) cells(d deck) []deck {
+	if len(d) == 0 {
+		return nil
+	}
+	if s == nil {
+		return []deck{d}
+	}
+
+	rd := newRiddle(d, s.q)
+	return append(s.yes.cells(rd.yes), s.no.cells(rd.no)...)
+}
+
+// questions is the expected number of questions asked before guessing
+func (s *strategy) questions(d deck) float64 {
+	if s == nil || len(d) == 0 {
+		return 0
+	}
+
+	rd := newRiddle(d, s.q)
+	n := float64(len(d))
+	return 1 + float64(len(rd.yes))/n*s.yes.questions(rd.yes) + float64(len(rd.no))/n*s.no.questions(rd.no)
+}
+
+// strategyStats measures a plan, the chance of winning is the number of cells
+// over the number of cards because we guess one card in each cell
+type strategyStats struct {
+	plan      *strategy
+	successes int
+	cards     int
+	gain      float64
+	// the expected number of questions, it is lower than the depth
+	// when a plan can stop early
+	questions float64
+}
+
+func (s strategyStats) success() float64 {
+	return float64(s.successes) / float64(s.cards)
+}
+
+func measure(d deck, plan *strategy) strategyStats {
+	cells := plan.cells(d)
+	return strategyStats{
+		plan:      plan,
+		successes: len(cells),
+		cards:     len(d),
+		gain:      partitionEntropy(cells, len(d)),
+		questions: plan.questions(d),
+	}
+}
+
+// better ranks plans by the chance of winning, then by the information they give,
+// then by the number of questions they need
+func (s strategyStats) better(o strategyStats) bool {
+	const eps = 1e-9
+	if s.successes != o.successes {
+		return s.successes > o.successes
+	}
+	if math.Abs(s.gain-o.gain) > eps {
+		return s.gain > o.gain
+	}
+	if math.Abs(s.questions-o.questions) > eps {
+		return s.questions < o.questions
+	}
+	return s.plan.String() < o.plan.String()
+}
+
+// partitionEntropy is the entropy in bits of the cell the hidden card falls in,
+// it is also the information the answers give about the card because
+// the answers are decided by the card
+func partitionEntropy(cells []deck, total int) float64 {
+	h := 0.0
+	for _, c := range cells {
+		if len(c) == 0 {
+			continue
+		}
+		p := float64(len(c)) / float64(total)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// rateQuestion measures a single question on the deck
+func rateQuestion(d deck, q question) questionStats {
+	stats := measure(d, &strategy{q: q})
+	return questionStats{
+		name:      q.name,
+		successes: stats.successes,
+		cards:     stats.cards,
+		gain:      stats.gain,
+	}
+}
+
+// bestPlan picks the best plan asking at most depth questions about the cards left,
+// questions that don't split the cards are never asked
+func bestPlan(d deck, qs []question, depth int) *strategy {
+	if depth == 0 || len(d) <= 1 {
+		return nil
+	}
+
+	var best *strategy
+	var bestStats strategyStats
+	for _, q := range qs {
+		rd := newRiddle(d, q)
+		if len(rd.yes) == 0 || len(rd.no) == 0 {
+			continue
+		}
+
+		plan := &strategy{q: q, yes: bestPlan(rd.yes, qs, depth-1), no: bestPlan(rd.no, qs, depth-1)}
+		stats := measure(d, plan)
+		if best == nil || stats.better(bestStats) {
+			best, bestStats = plan, stats
+		}
+	}
+	return best
+}
+
+// optimization is the ranked report of the optimizer
+type optimization struct {
+	questions  []questionStats
+	strategies []strategyStats
+	depth      int
+}
+
+// optimize rates every question on its own, then for each first question
+// searches the best follow up questions up to depth questions in total
+func optimize(d deck, qs []question, depth int) (optimization, error) {
+	if depth < 1 {
+		return optimization{}, fmt.Errorf("depth must be at least 1, got %d", depth)
+	}
+	if depth > maxDepth {
+		return optimization{}, fmt.Errorf("depth must be at most %d, got %d", maxDepth, depth)
+	}
+	if len(d) == 0 {
+		return optimization{}, fmt.Errorf("the deck is empty")
+	}
+
+	o := optimization{depth: depth}
+	for _, q := range qs {
+		o.questions = append(o.questions, rateQuestion(d, q))
+	}
+	sort.SliceStable(o.questions, func(i, j int) bool {
+		return o.questions[i].gain > o.questions[j].gain
+	})
+
+	for _, q := range qs {
+		rd := newRiddle(d, q)
+		plan := &strategy{q: q, yes: bestPlan(rd.yes, qs, depth-1), no: bestPlan(rd.no, qs, depth-1)}
+		o.strategies = append(o.strategies, measure(d, plan))
+	}
+	sort.SliceStable(o.strategies, func(i, j int) bool {
+		return o.strategies[i].better(o.strategies[j])
+	})
+	return o, nil
+}
+
+func (o optimization) write(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintln(&b, "questions:")
+	width := 13
+	for _, q := range o.questions {
+		if len(q.name)+1 > width {
+			width = len(q.name) + 1
+		}
+	}
+	for _, q := range o.questions {
+		fmt.Fprintf(&b, "  %-*s success %d/%d = %.4f  gain %.4f bits\n",
+			width, q.name+":", q.successes, q.cards, q.success(), q.gain)
+	}
+
+	fmt.Fprintf(&b, "strategies with up to %d questions:\n", o.depth)
+	for i, s := range o.strategies {
+		fmt.Fprintf(&b, "  %2d. success %d/%d = %.4f  gain %.4f bits  %.2f questions  %v\n",
+			i+1, s.successes, s.cards, s.success(), s.gain, s.questions, s.plan)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
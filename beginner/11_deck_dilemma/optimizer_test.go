@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestRateQuestion(t *testing.T) {
+	d := newDeck()
+
+	red := rateQuestion(d, question{"isRed", card.isRed})
+	if red.successes != 2 || math.Abs(red.gain-1) > 1e-9 {
+		t.Errorf("Expected 2 successes and 1 bit for isRed, but got %+v", red)
+	}
+
+	// 4 aces and 48 other cards
+	ace := rateQuestion(d, question{"isAce", card.isAce})
+	want := -(4.0/52)*math.Log2(4.0/52) - (48.0/52)*math.Log2(48.0/52)
+	if ace.successes != 2 || math.Abs(ace.gain-want) > 1e-9 {
+		t.Errorf("Expected 2 successes and %.4f bits for isAce, but got %+v", want, ace)
+	}
+
+	// a question that is always true splits nothing
+	always := rateQuestion(d, question{"always", func(card) bool { return true }})
+	if always.successes != 1 || always.gain != 0 {
+		t.Errorf("Expected 1 success and no gain for always, but got %+v", always)
+	}
+}
+
+func TestBestPlanStopsWhenNothingSplits(t *testing.T) {
+	d := Filter(newDeck(), card.isAce)
+
+	// no question tells the black aces apart, so the plan stops asking after isRed says no
+	plan := bestPlan(d, questions, 3)
+	s := measure(d, plan)
+	if s.successes != 3 || math.Abs(s.gain-1.5) > 1e-9 || s.questions != 1.5 {
+		t.Errorf("Expected 3 groups, 1.5 bits and 1.5 questions for the aces, but got %v with %+v", plan, s)
+	}
+}
+
+func TestOptimizeRanksStrategies(t *testing.T) {
+	o, err := optimize(newDeck(), questions, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(o.strategies) != len(questions) {
+		t.Fatalf("Expected a strategy for each first question, but got %v", len(o.strategies))
+	}
+	for i := 1; i < len(o.strategies); i++ {
+		if o.strategies[i].better(o.strategies[i-1]) {
+			t.Errorf("Expected %v to rank below %v", o.strategies[i].plan, o.strategies[i-1].plan)
+		}
+	}
+	// three questions can leave at most 8 groups of cards
+	if best := o.strategies[0]; best.successes != 8 {
+		t.Errorf("Expected the best strategy to win 8/52, but got %v/52 with %v", best.successes, best.plan)
+	}
+
+	var buf bytes.Buffer
+	if err := o.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), o.strategies[0].plan.String()) {
+		t.Errorf("Expected the report to show the best strategy, but got %v", buf.String())
+	}
+}
+
+func TestOptimizeRejectsBadDepth(t *testing.T) {
+	for _, depth := range []int{0, maxDepth + 1} {
+		if _, err := optimize(newDeck(), questions, depth); err == nil {
+			t.Errorf("Expected an error for a depth of %d, but got nil", depth)
+		}
+	}
+}
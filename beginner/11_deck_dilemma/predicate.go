@@ -0,0 +1,70 @@
+package main
+
+// Predicates work like the specifications in intermediate/0_solid/1_ocp,
+// a new filter is a new predicate or a combination of the ones we have,
+// the deck never needs a new method for it
+
+// And is satisfied when every predicate is, it is always satisfied without predicates
+func And(ps ...predicate) predicate {
+	return func(c card) bool {
+		for _, p := range ps {
+			if !p(c) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or is satisfied when any predicate is, it is never satisfied without predicates
+func Or(ps ...predicate) predicate {
+	return func(c card) bool {
+		for _, p := range ps {
+			if p(c) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func Not(p predicate) predicate {
+	return func(c card) bool {
+		return !p(c)
+	}
+}
+
+// Filter keeps the cards that satisfy the predicate in the order of the deck
+func Filter(d deck, p predicate) deck {
+	cards := deck{}
+	for _, c := range d {
+		if p(c) {
+			cards = append(cards, c)
+		}
+	}
+	return cards
+}
+
+// Partition splits the deck in the cards that satisfy the predicate and the ones that don't,
+// asking the predicate once for each card
+func Partition(d deck, p predicate) (deck, deck) {
+	yes, no := deck{}, deck{}
+	for _, c := range d {
+		if p(c) {
+			yes = append(yes, c)
+		} else {
+			no = append(no, c)
+		}
+	}
+	return yes, no
+}
+
+func Count(d deck, p predicate) int {
+	n := 0
+	for _, c := range d {
+		if p(c) {
+			n++
+		}
+	}
+	return n
+}
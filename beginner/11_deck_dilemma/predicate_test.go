@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestCombinators(t *testing.T) {
+	d := newDeck()
+
+	tests := []struct {
+		name string
+		p    predicate
+		want int
+	}{
+		{"red", card.isRed, 26},
+		{"black", Not(card.isRed), 26},
+		{"red face", And(card.isRed, card.isFace), 6},
+		{"ace or face", Or(card.isAce, card.isFace), 16},
+		{"neither ace nor face", Not(Or(card.isAce, card.isFace)), 36},
+		{"empty and", And(), 52},
+		{"empty or", Or(), 0},
+	}
+
+	for _, tt := range tests {
+		if n := Count(d, tt.p); n != tt.want {
+			t.Errorf("Expected %v cards for %v, but got %v", tt.want, tt.name, n)
+		}
+		if n := len(Filter(d, tt.p)); n != tt.want {
+			t.Errorf("Expected Filter to keep %v cards for %v, but got %v", tt.want, tt.name, n)
+		}
+	}
+}
+
+func TestPartition(t *testing.T) {
+	d := newDeck()
+	yes, no := Partition(d, card.isFace)
+
+	if len(yes) != 12 || len(no) != 40 {
+		t.Fatalf("Expected 12 and 40 cards, but got %v and %v", len(yes), len(no))
+	}
+	for _, c := range yes {
+		if !c.isFace() {
+			t.Errorf("Expected only faces on the first side, but got %v", c)
+		}
+	}
+	for _, c := range no {
+		if c.isFace() {
+			t.Errorf("Expected no faces on the second side, but got %v", c)
+		}
+	}
+}
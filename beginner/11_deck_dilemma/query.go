@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A query describes a question in text so it can be given on the command line,
+// for example "red && !face" or "(heart || spade) && rank > 7".
+//
+//	expr       = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | "(" expr ")" | name | "rank" comparison number
+//	comparison = "==" | "!=" | "<" | "<=" | ">" | ">="
+//
+// names are case insensitive, suits can be singular or plural
+
+// queryNames are the predicates a query can use by name
+var queryNames = map[string]predicate{
+	"red":   card.isRed,
+	"black": Not(card.isRed),
+	"face":  card.isFace,
+	"ace":   card.isAce,
+	"heart": card.isHeart,
+	"spade": func(c card) bool { return c.suit == "Spades" },
+	"club":  func(c card) bool { return c.suit == "Clubs" },
+	// the rest of the suits have no method of their own
+	"diamond": func(c card) bool { return c.suit == "Diamonds" },
+}
+
+var rankComparisons = map[string]func(a, b int) bool{
+	"==": func(a, b int) bool { return a == b },
+	"!=": func(a, b int) bool { return a != b },
+	"<":  func(a, b int) bool { return a < b },
+	"<=": func(a, b int) bool { return a <= b },
+	">":  func(a, b int) bool { return a > b },
+	">=": func(a, b int) bool { return a >= b },
+}
+
+// parseQuery compiles a query into a question named after the query
+func parseQuery(s string) (question, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return question{}, fmt.Errorf("query %q: %w", s, err)
+	}
+
+	p := &queryParser{tokens: tokens}
+	ask, err := p.expr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	if err != nil {
+		return question{}, fmt.Errorf("query %q: %w", s, err)
+	}
+	return question{name: strings.TrimSpace(s), ask: ask}, nil
+}
+
+// tokenize splits a query in operators, parenthesis, names and numbers
+func tokenize(s string) ([]string, error) {
+	tokens := []string{}
+	for i := 0; i < len(s); {
+		ch := rune(s[i])
+		switch {
+		case unicode.IsSpace(ch):
+			i++
+		case strings.ContainsRune("()", ch):
+			tokens = append(tokens, s[i:i+1])
+			i++
+		case strings.HasPrefix(s[i:], "&&") || strings.HasPrefix(s[i:], "||"):
+			tokens = append(tokens, s[i:i+2])
+			i += 2
+		case strings.ContainsRune("!=<>", ch):
+			// the two character comparisons first, so "!=" is not read as a not
+			if i+1 < len(s) && s[i+1] == '=' {
+				tokens = append(tokens, s[i:i+2])
+				i += 2
+			} else if ch == '=' {
+				return nil, fmt.Errorf("unexpected %q at %d, did you mean ==", ch, i)
+			} else {
+				tokens = append(tokens, s[i:i+1])
+				i++
+			}
+		case unicode.IsLetter(ch) || unicode.IsDigit(ch):
+			start := i
+			for i < len(s) && (unicode.IsLetter(rune(s[i])) || unicode.IsDigit(rune(s[i]))) {
+				i++
+			}
+			tokens = append(tokens, s[start:i])
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", ch, i)
+		}
+	}
+	return tokens, nil
+}
+
+// queryParser is a recursive descent parser, each method reads one rule of the grammar
+type queryParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *queryParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *queryParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *queryParser) expr() (predicate, error) {
+	first, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	ps := []predicate{first}
+	for p.peek() == "||" {
+		p.next()
+		next, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, next)
+	}
+
+	if len(ps) == 1 {
+		return first, nil
+	}
+	return Or(ps...), nil
+}
+
+func (p *queryParser) and() (predicate, error) {
+	first, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	ps := []predicate{first}
+	for p.peek() == "&&" {
+		p.next()
+		next, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, next)
+	}
+
+	if len(ps) == 1 {
+		return first, nil
+	}
+	return And(ps...), nil
+}
+
+func (p *queryParser) unary() (predicate, error) {
+	t := p.next()
+	switch t {
+	case "":
+		return nil, fmt.Errorf("unexpected end of query")
+	case "!":
+		inner, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return Not(inner), nil
+	case "(":
+		inner, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return inner, nil
+	}
+
+	name := strings.ToLower(t)
+	if name == "rank" {
+		return p.rank()
+	}
+	if ask, ok := queryNames[strings.TrimSuffix(name, "s")]; ok {
+		return ask, nil
+	}
+	return nil, fmt.Errorf("unknown name %q", t)
+}
+
+// rank reads the comparison after the word rank, "rank > 7" keeps the cards above the Seven
+func (p *queryParser) rank() (predicate, error) {
+	op := p.next()
+	compare, ok := rankComparisons[op]
+	if !ok {
+		return nil, fmt.Errorf("expected a comparison after rank, got %q", op)
+	}
+
+	t := p.next()
+	n, err := strconv.Atoi(t)
+	if err != nil {
+		return nil, fmt.Errorf("expected a number after rank %v, got %q", op, t)
+	}
+	return func(c card) bool {
+		return compare(c.rank(), n)
+	}, nil
+}
+
+// queryFlags collects the question of every -query given on the command line
+type queryFlags []question
+
+func (q *queryFlags) String() string {
+	names := []string{}
+	for _, question := range *q {
+		names = append(names, question.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func (q *queryFlags) Set(s string) error {
+	question, err := parseQuery(s)
+	if err != nil {
+		return err
+	}
+	*q = append(*q, question)
+	return nil
+}
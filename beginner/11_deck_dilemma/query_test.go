@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestParseQuery(t *testing.T) {
+	d := newDeck()
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"red", 26},
+		{"red && !face", 20},
+		{"!red && face", 6},
+		{"Hearts || spades", 26},
+		{"ace || face && red", 10},
+		{"(ace || face) && red", 8},
+		{"!(black || ace)", 24},
+		{"rank > 7", 24},
+		{"rank == 1", 4},
+		{"rank != 1 && diamond", 12},
+		{"club && rank <= 3 || rank >= 13", 7},
+	}
+
+	for _, tt := range tests {
+		q, err := parseQuery(tt.query)
+		if err != nil {
+			t.Errorf("Expected %q to parse, but got %v", tt.query, err)
+			continue
+		}
+		if q.name != tt.query {
+			t.Errorf("Expected the question to be named %q, but got %q", tt.query, q.name)
+		}
+		if n := Count(d, q.ask); n != tt.want {
+			t.Errorf("Expected %v cards for %q, but got %v", tt.want, tt.query, n)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, query := range []string{"", "red &&", "purple", "(red", "red)", "red face", "rank = 7", "rank >", "rank > seven", "red & face", "rank 7"} {
+		if _, err := parseQuery(query); err == nil {
+			t.Errorf("Expected an error for %q, but got nil", query)
+		}
+	}
+}
+
+func TestQueryFlags(t *testing.T) {
+	queries := queryFlags{}
+	if err := queries.Set("red && !face"); err != nil {
+		t.Fatal(err)
+	}
+	if err := queries.Set("ace"); err != nil {
+		t.Fatal(err)
+	}
+	if err := queries.Set("red &&"); err == nil {
+		t.Error("Expected an error for an incomplete query, but got nil")
+	}
+
+	if s := queries.String(); s != "red && !face, ace" {
+		t.Errorf("Expected both queries, but got %q", s)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import "math/rand"
+
+// predicate is a yes or no question about a card
+type predicate func(c card) bool
+
+// question is a predicate with a name to report it by
+type question struct {
+	name string
+	ask  predicate
+}
+
+type result struct {
+	name      string
+	trials    int
+	successes int
+}
+
+func (r result) probability() float64 {
+	if r.trials == 0 {
+		return 0
+	}
+	return float64(r.successes) / float64(r.trials)
+}
+
+// riddle holds the cards that give each answer to a question,
+// so the deck is filtered once and not on every guess
+type riddle struct {
+	d   deck
+	q   question
+	yes deck
+	no  deck
+}
+
+func newRiddle(d deck, q question) riddle {
+	yes, no := Partition(d, q.ask)
+	return riddle{d: d, q: q, yes: yes, no: no}
+}
+
+// guess plays the riddle once, the friend picks a card and answers the question,
+// then we guess at random among the cards that give the same answer
+func (rd riddle) guess(r *rand.Rand) bool {
+	c := rd.d.random(r)
+	candidates := rd.no
+	if rd.q.ask(c) {
+		candidates = rd.yes
+	}
+	return candidates.random(r) == c
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestNewRiddleSplitsTheDeck(t *testing.T) {
+	for _, q := range questions[:3] {
+		rd := newRiddle(newDeck(), q)
+		if len(rd.yes)+len(rd.no) != 52 || len(rd.yes) != Count(newDeck(), q.ask) {
+			t.Errorf("Expected %v to split the deck in its answers, but got %v/%v", q.name, len(rd.yes), len(rd.no))
+		}
+	}
+}
+
+// every question gives the same 1/26 chance, the riddle is about that
+func TestGuessProbability(t *testing.T) {
+	// the question of the original riddle
+	aceOfSpades := question{"isAceOfSpades", func(c card) bool { return c.value == "Ace" && c.suit == "Spades" }}
+
+	r := rand.New(rand.NewSource(11))
+	for _, q := range append(questions[:3:3], aceOfSpades) {
+		rd := newRiddle(newDeck(), q)
+		res := result{name: q.name, trials: 100000}
+		for i := 0; i < res.trials; i++ {
+			if rd.guess(r) {
+				res.successes++
+			}
+		}
+
+		// about 4 standard errors
+		if math.Abs(res.probability()-1.0/26) > 0.0025 {
+			t.Errorf("Expected about %.4f for %v, but got %.4f", 1.0/26, q.name, res.probability())
+		}
+	}
+}
+
+func TestResultProbability(t *testing.T) {
+	if p := (result{trials: 0}).probability(); p != 0 {
+		t.Errorf("Expected 0 without trials, but got %v", p)
+	}
+	if p := (result{trials: 26, successes: 1}).probability(); p != 1.0/26 {
+		t.Errorf("Expected 1/26, but got %v", p)
+	}
+}
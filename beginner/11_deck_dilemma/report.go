@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang_tutorial/beginner/11_deck_dilemma/fraction"
+)
+
+// report is everything a run found, it can be written in any of the reportWriters formats
+type report struct {
+	Seed      int64  `json:"seed"`
+	Workers   int    `json:"workers"`
+	Duration  string `json:"duration"`
+	GoVersion string `json:"go_version"`
+	// the run was cancelled before every trial was played
+	Interrupted bool             `json:"interrupted"`
+	Questions   []questionReport `json:"questions"`
+}
+
+type questionReport struct {
+	Name        string  `json:"name"`
+	Trials      int     `json:"trials"`
+	Successes   int     `json:"successes"`
+	Probability float64 `json:"probability"`
+	// the simplest fraction inside the confidence interval
+	Approximation string  `json:"approximation"`
+	Exact         string  `json:"exact"`
+	ExactValue    float64 `json:"exact_value"`
+	Error         float64 `json:"error"`
+	CILow         float64 `json:"ci_low"`
+	CIHigh        float64 `json:"ci_high"`
+	// the exact value falls outside the confidence interval
+	Outside bool `json:"outside"`
+}
+
+var reportWriters = map[string]func(r report, w io.Writer) error{
+	"text":     report.writeText,
+	"json":     report.writeJSON,
+	"csv":      report.writeCSV,
+	"markdown": report.writeMarkdown,
+	"md":       report.writeMarkdown,
+}
+
+func reportFormats() []string {
+	names := []string{}
+	for name := range reportWriters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newReport(d deck, qs []question, results []result, cfg simConfig, duration time.Duration, interrupted bool) report {
+	r := report{
+		Seed:        cfg.seed,
+		Workers:     cfg.workers,
+		Duration:    duration.Round(time.Millisecond).String(),
+		GoVersion:   runtime.Version(),
+		Interrupted: interrupted,
+	}
+
+	for i, res := range results {
+		c := compare(res, exactProbability(d, qs[i]))
+		exact, _ := c.exact.Float64()
+		f, _ := fraction.WithTolerance(res.probability(), (c.high-c.low)/2)
+
+		r.Questions = append(r.Questions, questionReport{
+			Name:          res.name,
+			Trials:        res.trials,
+			Successes:     res.successes,
+			Probability:   res.probability(),
+			Approximation: f.String(),
+			Exact:         c.exact.RatString(),
+			ExactValue:    exact,
+			Error:         c.absError,
+			CILow:         c.low,
+			CIHigh:        c.high,
+			Outside:       c.outside,
+		})
+	}
+	return r
+}
+
+func (r report) writeText(w io.Writer) error {
+	fmt.Fprintln(w, "seed:", r.Seed)
+	fmt.Fprintln(w, "probabilities:")
+	// queries can have long names, the columns are as wide as the longest one
+	width := 13
+	for _, q := range r.Questions {
+		if len(q.Name)+1 > width {
+			width = len(q.Name) + 1
+		}
+	}
+	for _, q := range r.Questions {
+		// questions whose exact value falls outside the interval are flagged with a !
+		mark := ""
+		if q.Outside {
+			mark = " !"
+		}
+		fmt.Fprintf(w, "  %-*s %.4f ~ %v  exact %v = %.4f  error %.4f  95%% ci [%.4f, %.4f]%v\n",
+			width, q.Name+":", q.Probability, q.Approximation, q.Exact, q.ExactValue, q.Error, q.CILow, q.CIHigh, mark)
+	}
+	_, err := fmt.Fprintf(w, "took %v with %v\n", r.Duration, r.GoVersion)
+	return err
+}
+
+func (r report) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// writeCSV writes a row per question, the details of the run are
+// repeated on every row so each row stands on its own
+func (r report) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"question", "trials", "successes", "probability", "approximation", "exact", "exact_value",
+		"error", "ci_low", "ci_high", "outside", "seed", "workers", "duration", "go_version", "interrupted",
+	})
+
+	float := func(f float64) string {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	for _, q := range r.Questions {
+		cw.Write([]string{
+			q.Name, strconv.Itoa(q.Trials), strconv.Itoa(q.Successes), float(q.Probability), q.Approximation,
+			q.Exact, float(q.ExactValue), float(q.Error), float(q.CILow), float(q.CIHigh), strconv.FormatBool(q.Outside),
+			strconv.FormatInt(r.Seed, 10), strconv.Itoa(r.Workers), r.Duration, r.GoVersion, strconv.FormatBool(r.Interrupted),
+		})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func (r report) writeMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**seed** %v, **workers** %v, **duration** %v, **go** %v", r.Seed, r.Workers, r.Duration, r.GoVersion)
+	if r.Interrupted {
+		b.WriteString(", **interrupted**")
+	}
+	b.WriteString("\n\n")
+
+	b.WriteString("| question | trials | successes | probability | approximation | exact | error | 95% ci | outside |\n")
+	b.WriteString("|---|---:|---:|---:|---:|---:|---:|---|---|\n")
+	for _, q := range r.Questions {
+		outside := ""
+		if q.Outside {
+			outside = "yes"
+		}
+		fmt.Fprintf(&b, "| %v | %d | %d | %.4f | %v | %v | %.4f | [%.4f, %.4f] | %v |\n",
+			markdownCell(q.Name), q.Trials, q.Successes, q.Probability, q.Approximation, q.Exact, q.Error, q.CILow, q.CIHigh, outside)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownCell escapes the pipes and removes the newlines that would break the table,
+// a query like "heart || spade" is a valid question name
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\r\n", " ", "\n", " ", "\r", " ").Replace(s)
+}
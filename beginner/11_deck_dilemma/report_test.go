@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testReport(t *testing.T) report {
+	d := newDeck()
+	cfg := simConfig{trials: 2000, workers: 2, seed: 7}
+	results, err := simulate(context.Background(), d, questions, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newReport(d, questions, results, cfg, 1500*time.Millisecond, false)
+}
+
+func TestNewReport(t *testing.T) {
+	r := testReport(t)
+
+	if r.Seed != 7 || r.Workers != 2 || r.Duration != "1.5s" || r.GoVersion == "" {
+		t.Errorf("Expected the run details, but got %+v", r)
+	}
+	if len(r.Questions) != len(questions) {
+		t.Fatalf("Expected %v questions, but got %v", len(questions), len(r.Questions))
+	}
+	for _, q := range r.Questions {
+		if q.Trials != 2000 || q.Exact != "1/26" {
+			t.Errorf("Expected 2000 trials and an exact 1/26, but got %+v", q)
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	r := testReport(t)
+
+	var buf bytes.Buffer
+	if err := r.writeJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	got := report{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Seed != r.Seed || len(got.Questions) != len(r.Questions) || got.Questions[0] != r.Questions[0] {
+		t.Errorf("Expected %+v, but got %+v", r, got)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	r := testReport(t)
+
+	var buf bytes.Buffer
+	if err := r.writeCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(questions)+1 {
+		t.Fatalf("Expected a header and %v rows, but got %v records", len(questions), len(records))
+	}
+	if records[1][0] != questions[0].name || records[1][11] != "7" {
+		t.Errorf("Expected the question and the seed on every row, but got %v", records[1])
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	r := testReport(t)
+	r.Interrupted = true
+
+	var buf bytes.Buffer
+	if err := r.writeMarkdown(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	s := buf.String()
+	if !strings.Contains(s, "**interrupted**") {
+		t.Errorf("Expected the report to say it was interrupted, but got %v", s)
+	}
+	// the header, the separator and a row per question
+	if rows := strings.Count(s, "\n|"); rows != len(questions)+2 {
+		t.Errorf("Expected %v table rows, but got %v", len(questions)+2, rows)
+	}
+}
+
+func TestWriteMarkdownEscapesQueries(t *testing.T) {
+	q, err := parseQuery("heart ||\nspade")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := newDeck()
+	cfg := simConfig{trials: 100, workers: 1, seed: 7}
+	results, _ := simulate(context.Background(), d, []question{q}, cfg)
+	r := newReport(d, []question{q}, results, cfg, time.Second, false)
+
+	var buf bytes.Buffer
+	if err := r.writeMarkdown(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	row := lines[len(lines)-1]
+	if !strings.HasPrefix(row, `| heart \|\| spade |`) {
+		t.Errorf("Expected the pipes to be escaped and the newline removed, but got %v", row)
+	}
+	// every row has as many columns as the header
+	columns := func(s string) int { return strings.Count(s, "|") - strings.Count(s, `\|`) }
+	if columns(row) != columns(lines[2]) {
+		t.Errorf("Expected %v columns, but got %v in %v", columns(lines[2]), columns(row), row)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// trials are handed to the workers in batches so the channel
+// is not touched on every guess
+const batchSize = 1000
+
+// how often the progress is reported
+const progressInterval = 200 * time.Millisecond
+
+type simConfig struct {
+	// number of guesses for each question
+	trials int
+	// number of goroutines, runtime.NumCPU() when 0
+	workers int
+	// master seed, the same seed always gives the same results
+	// no matter how many workers there are
+	seed int64
+	// called from a single goroutine with the number of guesses done,
+	// the last call has done == total
+	progress func(done int, total int)
+}
+
+// batch is a number of guesses of a single question, index numbers
+// the batches in order so each one can derive its own seed
+type batch struct {
+	index    int
+	question int
+	trials   int
+}
+
+// batchSeed derives an independent seed for every batch from the master seed
+// with the splitmix64 mixer, so nearby seeds don't give correlated sources
+func batchSeed(seed int64, index int) int64 {
+	z := uint64(seed) + uint64(index+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return int64(z ^ (z >> 31))
+}
+
+// counts are local to a worker and merged when every worker is done
+type counts struct {
+	trials    []int
+	successes []int
+}
+
+// simulate plays the riddle cfg.trials times for each question on a bounded
+// pool of workers, when the context is cancelled it returns the results
+// of the guesses done so far and the context error
+func simulate(ctx context.Context, d deck, qs []question, cfg simConfig) ([]result, error) {
+	workers := cfg.workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	riddles := []riddle{}
+	for _, q := range qs {
+		riddles = append(riddles, newRiddle(d, q))
+	}
+
+	batches := make(chan batch, workers)
+	go func() {
+		defer close(batches)
+		index := 0
+		for i := range qs {
+			for left := cfg.trials; left > 0; left -= batchSize {
+				n := batchSize
+				if left < n {
+					n = left
+				}
+				select {
+				case batches <- batch{index: index, question: i, trials: n}:
+				case <-ctx.Done():
+					return
+				}
+				index++
+			}
+		}
+	}()
+
+	var done int64
+	local := make([]counts, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			// the worker owns its source and reseeds it for every batch,
+			// so it doesn't matter which worker plays which batch
+			r := rand.New(rand.NewSource(cfg.seed))
+			c := counts{trials: make([]int, len(qs)), successes: make([]int, len(qs))}
+			for b := range batches {
+				if ctx.Err() != nil {
+					continue
+				}
+				r.Seed(batchSeed(cfg.seed, b.index))
+				for i := 0; i < b.trials; i++ {
+					if riddles[b.question].guess(r) {
+						c.successes[b.question]++
+					}
+				}
+				c.trials[b.question] += b.trials
+				atomic.AddInt64(&done, int64(b.trials))
+			}
+			local[w] = c
+		}(w)
+	}
+
+	finished := make(chan struct{})
+	reported := make(chan struct{})
+	go func() {
+		defer close(reported)
+		reportProgress(cfg.progress, &done, cfg.trials*len(qs), finished)
+	}()
+
+	wg.Wait()
+	close(finished)
+	<-reported
+
+	results := []result{}
+	for i, q := range qs {
+		r := result{name: q.name}
+		for _, c := range local {
+			r.trials += c.trials[i]
+			r.successes += c.successes[i]
+		}
+		results = append(results, r)
+	}
+	return results, ctx.Err()
+}
+
+func reportProgress(progress func(int, int), done *int64, total int, finished chan struct{}) {
+	if progress == nil {
+		return
+	}
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			progress(int(atomic.LoadInt64(done)), total)
+		case <-finished:
+			progress(int(atomic.LoadInt64(done)), total)
+			return
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestSimulateCountsEveryTrial(t *testing.T) {
+	results, err := simulate(context.Background(), newDeck(), questions, simConfig{trials: 2500, workers: 3})
+	if err != nil {
+		t.Fatalf("Expected the simulation to finish, but got %v", err)
+	}
+
+	for _, r := range results {
+		if r.trials != 2500 {
+			t.Errorf("Expected 2500 trials for %v, but got %v", r.name, r.trials)
+		}
+		if r.successes == 0 || r.successes > r.trials/10 {
+			t.Errorf("Expected about 1/26 successes for %v, but got %v", r.name, r.successes)
+		}
+	}
+}
+
+func TestSimulateSameSeedSameCounts(t *testing.T) {
+	d := newDeck()
+	a, _ := simulate(context.Background(), d, questions, simConfig{trials: 20000, workers: 4, seed: 26})
+	b, _ := simulate(context.Background(), d, questions, simConfig{trials: 20000, workers: 1, seed: 26})
+	c, _ := simulate(context.Background(), d, questions, simConfig{trials: 20000, workers: 4, seed: 27})
+
+	different := false
+	for i := range a {
+		if a[i] != b[i] {
+			t.Errorf("Expected the same counts for %v with the same seed, but got %+v and %+v", a[i].name, a[i], b[i])
+		}
+		if a[i] != c[i] {
+			different = true
+		}
+	}
+
+	if !different {
+		t.Errorf("Expected different seeds to give different counts")
+	}
+}
+
+func TestBatchSeedsAreDistinct(t *testing.T) {
+	seen := map[int64]bool{}
+	for seed := int64(0); seed < 10; seed++ {
+		for i := 0; i < 1000; i++ {
+			seen[batchSeed(seed, i)] = true
+		}
+	}
+
+	if len(seen) != 10000 {
+		t.Errorf("Expected 10000 distinct seeds, but got %v", len(seen))
+	}
+}
+
+func TestSimulateProgress(t *testing.T) {
+	last, total := 0, 0
+	cfg := simConfig{trials: 5000, workers: 2, progress: func(done int, t int) {
+		last, total = done, t
+	}}
+
+	if _, err := simulate(context.Background(), newDeck(), questions[:2], cfg); err != nil {
+		t.Fatalf("Expected the simulation to finish, but got %v", err)
+	}
+
+	if last != 10000 || total != 10000 {
+		t.Errorf("Expected the last report to be 10000/10000, but got %v/%v", last, total)
+	}
+}
+
+func TestSimulateProgressWithoutTrials(t *testing.T) {
+	var out bytes.Buffer
+	cfg := simConfig{trials: 0, workers: 2, progress: printProgress(&out)}
+
+	results, err := simulate(context.Background(), newDeck(), questions[:2], cfg)
+	if err != nil {
+		t.Fatalf("Expected the simulation to finish, but got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no progress without trials, but got %q", out.String())
+	}
+	for _, r := range results {
+		if r.trials != 0 || r.probability() != 0 {
+			t.Errorf("Expected no trials for %v, but got %+v", r.name, r)
+		}
+	}
+}
+
+func TestPrintProgress(t *testing.T) {
+	var out bytes.Buffer
+	p := printProgress(&out)
+	p(50, 200)
+	p(200, 200)
+
+	if out.String() != "\r 25% 50/200\r100% 200/200\n" {
+		t.Errorf("Expected 25%% then 100%% on the same line, but got %q", out.String())
+	}
+}
+
+func TestSimulateCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err := simulate(ctx, newDeck(), questions, simConfig{trials: 1000000})
+	if err != context.Canceled {
+		t.Errorf("Expected %v, but got %v", context.Canceled, err)
+	}
+
+	for _, r := range results {
+		if r.trials == 1000000 {
+			t.Errorf("Expected %v to stop early", r.name)
+		}
+	}
+}
+
+// simulateGoroutinePerTrial is the original design, one goroutine per guess,
+// every result sent on an unbuffered channel and the global source reseeded
+// on every draw, it is only kept to compare the throughput
+func simulateGoroutinePerTrial(d deck, qs []question, trials int) map[string]int {
+	ch := make(chan string)
+	random := func(d deck) card {
+		rand.Seed(time.Now().UnixNano())
+		return d[rand.Intn(len(d))]
+	}
+
+	for _, q := range qs {
+		for i := 0; i < trials; i++ {
+			go func(q question) {
+				c := random(d)
+				answer := q.ask(c)
+				candidates := Filter(d, func(o card) bool {
+					return q.ask(o) == answer
+				})
+				if random(candidates) == c {
+					ch <- q.name
+					return
+				}
+				ch <- ""
+			}(q)
+		}
+	}
+
+	count := map[string]int{}
+	for i := 0; i < trials*len(qs); i++ {
+		count[<-ch]++
+	}
+	return count
+}
+
+func BenchmarkGoroutinePerTrial(b *testing.B) {
+	start := time.Now()
+	simulateGoroutinePerTrial(newDeck(), questions[:3], b.N)
+	b.ReportMetric(float64(3*b.N)/time.Since(start).Seconds(), "guesses/s")
+}
+
+func BenchmarkWorkerPool(b *testing.B) {
+	start := time.Now()
+	simulate(context.Background(), newDeck(), questions[:3], simConfig{trials: b.N})
+	b.ReportMetric(float64(3*b.N)/time.Since(start).Seconds(), "guesses/s")
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// simulations are split in batches of a fixed size, each batch has its own seed
+// so the results only depend on the seed and not on the number of workers
+const batchSize = 1000
+
+// batch is a part of a simulation, index numbers the batches in order
+type batch struct {
+	index int
+	n     int
+	seed  int64
+}
+
+// batchSeed derives an independent seed for every batch from the master seed
+// with the splitmix64 mixer, so nearby seeds don't give correlated sources
+func batchSeed(seed int64, index int) int64 {
+	z := uint64(seed) + uint64(index+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return int64(z ^ (z >> 31))
+}
+
+// batchCount is the number of batches needed for total iterations
+func batchCount(total int) int {
+	return (total + batchSize - 1) / batchSize
+}
+
+// runBatches splits total iterations in batches and runs them on the workers,
+// fn is called once per batch and can keep its result at b.index
+func runBatches(total int, seed int64, workers int, fn func(b batch)) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	batches := make(chan batch)
+	go func() {
+		defer close(batches)
+		for i := 0; i < batchCount(total); i++ {
+			n := batchSize
+			if left := total - i*batchSize; left < n {
+				n = left
+			}
+			batches <- batch{index: i, n: n, seed: batchSeed(seed, i)}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				fn(b)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// blackjackRules are the table rules, defaultBlackjackRules is a common
+// six deck game
+type blackjackRules struct {
+	decks int
+	// the cut card is placed after this fraction of the shoe
+	penetration float64
+	// H17 when true, S17 when false
+	dealerHitsSoft17 bool
+	blackjackPays    float64
+	doubleAfterSplit bool
+	// the most hands a player can have after splitting
+	maxHands int
+	// late surrender, after the dealer checked for blackjack
+	surrender bool
+	insurance bool
+}
+
+func defaultBlackjackRules() blackjackRules {
+	return blackjackRules{
+		decks:            6,
+		penetration:      0.75,
+		dealerHitsSoft17: false,
+		blackjackPays:    1.5,
+		doubleAfterSplit: true,
+		maxHands:         4,
+		surrender:        true,
+		insurance:        true,
+	}
+}
+
+func (r blackjackRules) validate() error {
+	if r.decks < 1 {
+		return fmt.Errorf("blackjack needs at least 1 deck, got %d", r.decks)
+	}
+	if r.penetration <= 0 || r.penetration > 1 {
+		return fmt.Errorf("penetration must be between 0 and 1, got %v", r.penetration)
+	}
+	if r.maxHands < 1 {
+		return fmt.Errorf("a player needs at least 1 hand, got %d", r.maxHands)
+	}
+	return nil
+}
+
+// Action is a player decision
+type Action int
+
+const (
+	Stand Action = iota
+	Hit
+	Double
+	Split
+	Surrender
+)
+
+var actionNames = []string{"Stand", "Hit", "Double", "Split", "Surrender"}
+
+func (a Action) String() string {
+	if a < 0 || int(a) >= len(actionNames) {
+		return fmt.Sprintf("Action(%d)", int(a))
+	}
+	return actionNames[a]
+}
+
+// Situation is everything a strategy can see when it has to act
+type Situation struct {
+	Hand     deck
+	DealerUp Card
+	// the number of hands the player has, more than 1 after a split
+	Hands        int
+	CanDouble    bool
+	CanSplit     bool
+	CanSurrender bool
+}
+
+func (s Situation) allows(a Action) bool {
+	switch a {
+	case Stand, Hit:
+		return true
+	case Double:
+		return s.CanDouble
+	case Split:
+		return s.CanSplit
+	case Surrender:
+		return s.CanSurrender
+	}
+	return false
+}
+
+// Strategy makes the player decisions, a game calls it from a single
+// goroutine so implementations may keep state like a card count
+type Strategy interface {
+	Decide(s Situation) Action
+	TakeInsurance(hand deck, dealerUp Card) bool
+}
+
+// blackjackValue counts the Ace as 1 and faces as 10
+func blackjackValue(c Card) int {
+	if c.IsFace() {
+		return 10
+	}
+	return int(c.Rank)
+}
+
+// handTotal is the best total of the hand, it is soft when an Ace
+// is counted as 11
+func handTotal(cards deck) (int, bool) {
+	total, aces := 0, 0
+	for _, c := range cards {
+		total += blackjackValue(c)
+		if c.Rank == Ace {
+			aces++
+		}
+	}
+
+	if aces > 0 && total+10 <= 21 {
+		return total + 10, true
+	}
+	return total, false
+}
+
+func isBlackjack(cards deck) bool {
+	total, _ := handTotal(cards)
+	return len(cards) == 2 && total == 21
+}
+
+// bjShoe is the deck the game deals from, it is shuffled
+// when the cut card comes out
+type bjShoe struct {
+	cards  deck
+	next   int
+	cut    int
+	source rand.Source
+}
+
+func newBJShoe(rules blackjackRules, source rand.Source) *bjShoe {
+	cards, _ := newShoeBuilder(rules.decks).build()
+	s := &bjShoe{
+		cards:  cards,
+		cut:    int(float64(len(cards)) * rules.penetration),
+		source: source,
+	}
+	s.shuffle()
+	return s
+}
+
+func (s *bjShoe) shuffle() {
+	s.cards.shuffleWith(s.source)
+	s.next = 0
+}
+
+func (s *bjShoe) pastCutCard() bool {
+	return s.next >= s.cut
+}
+
+func (s *bjShoe) draw() Card {
+	// only reachable with a very deep cut card and many splits
+	if s.next >= len(s.cards) {
+		s.shuffle()
+	}
+	c := s.cards[s.next]
+	s.next++
+	return c
+}
+
+type bjHand struct {
+	cards       deck
+	bet         float64
+	fromSplit   bool
+	splitAces   bool
+	doubled     bool
+	surrendered bool
+}
+
+func (h *bjHand) busted() bool {
+	total, _ := handTotal(h.cards)
+	return total > 21
+}
+
+type blackjackGame struct {
+	rules    blackjackRules
+	shoe     *bjShoe
+	strategy Strategy
+}
+
+func newBlackjackGame(rules blackjackRules, strategy Strategy, source rand.Source) (*blackjackGame, error) {
+	if err := rules.validate(); err != nil {
+		return nil, err
+	}
+	return &blackjackGame{rules: rules, shoe: newBJShoe(rules, source), strategy: strategy}, nil
+}
+
+// roundResult is the outcome of a round for a single player
+type roundResult struct {
+	// the money won or lost, including insurance
+	net float64
+	// every bet put on the table, including doubles, splits and insurance
+	wagered float64
+}
+
+// playRound plays one round with an initial bet and returns what
+// the player won, an error means the strategy chose an illegal action
+func (g *blackjackGame) playRound(bet float64) (roundResult, error) {
+	if g.shoe.pastCutCard() {
+		g.shoe.shuffle()
+	}
+
+	player := deck{g.shoe.draw()}
+	dealer := deck{g.shoe.draw()}
+	player = append(player, g.shoe.draw())
+	dealer = append(dealer, g.shoe.draw())
+	up := dealer[0]
+
+	result := roundResult{wagered: bet}
+
+	if g.rules.insurance && up.Rank == Ace && g.strategy.TakeInsurance(player, up) {
+		insurance := bet / 2
+		result.wagered += insurance
+		if isBlackjack(dealer) {
+			result.net += 2 * insurance
+		} else {
+			result.net -= insurance
+		}
+	}
+
+	// the dealer peeks for blackjack before the player acts
+	if isBlackjack(dealer) {
+		if !isBlackjack(player) {
+			result.net -= bet
+		}
+		return result, nil
+	}
+
+	if isBlackjack(player) {
+		result.net += bet * g.rules.blackjackPays
+		return result, nil
+	}
+
+	hands := []*bjHand{{cards: player, bet: bet}}
+	for i := 0; i < len(hands); i++ {
+		more, err := g.playHand(hands[i], len(hands), up)
+		if err != nil {
+			return result, err
+		}
+		hands = append(hands, more...)
+	}
+
+	// the first hand was counted when the round started
+	result.wagered -= bet
+	dealerPlays := false
+	for _, h := range hands {
+		result.wagered += h.bet
+		if !h.busted() && !h.surrendered {
+			dealerPlays = true
+		}
+	}
+
+	if dealerPlays {
+		dealer = g.playDealer(dealer)
+	}
+	dealerTotal, _ := handTotal(dealer)
+
+	for _, h := range hands {
+		total, _ := handTotal(h.cards)
+		switch {
+		case h.surrendered:
+			result.net -= h.bet / 2
+		case total > 21:
+			result.net -= h.bet
+		case dealerTotal > 21 || total > dealerTotal:
+			result.net += h.bet
+		case total < dealerTotal:
+			result.net -= h.bet
+		}
+	}
+	return result, nil
+}
+
+// playHand asks the strategy for actions until the hand is done,
+// it returns the new hand when the player splits
+func (g *blackjackGame) playHand(h *bjHand, hands int, up Card) ([]*bjHand, error) {
+	split := []*bjHand{}
+
+	for {
+		total, _ := handTotal(h.cards)
+		// split aces get a single card each
+		if total >= 21 || h.splitAces || h.doubled {
+			return split, nil
+		}
+
+		first := len(h.cards) == 2
+		s := Situation{
+			Hand:         h.cards,
+			DealerUp:     up,
+			Hands:        hands + len(split),
+			CanDouble:    first && (!h.fromSplit || g.rules.doubleAfterSplit),
+			CanSplit:     first && h.cards[0].Rank == h.cards[1].Rank && hands+len(split) < g.rules.maxHands,
+			CanSurrender: first && g.rules.surrender && hands == 1 && !h.fromSplit,
+		}
+
+		a := g.strategy.Decide(s)
+		if !s.allows(a) {
+			return nil, fmt.Errorf("strategy chose %v, which is not allowed with %v against %v", a, h.cards, up)
+		}
+
+		switch a {
+		case Stand:
+			return split, nil
+		case Hit:
+			h.cards = append(h.cards, g.shoe.draw())
+		case Double:
+			h.bet *= 2
+			h.doubled = true
+			h.cards = append(h.cards, g.shoe.draw())
+		case Surrender:
+			h.surrendered = true
+			return split, nil
+		case Split:
+			aces := h.cards[0].Rank == Ace
+			other := &bjHand{cards: deck{h.cards[1], g.shoe.draw()}, bet: h.bet, fromSplit: true, splitAces: aces}
+			h.cards = deck{h.cards[0], g.shoe.draw()}
+			h.fromSplit = true
+			h.splitAces = aces
+			split = append(split, other)
+		}
+	}
+}
+
+func (g *blackjackGame) playDealer(dealer deck) deck {
+	for {
+		total, soft := handTotal(dealer)
+		if total > 17 || (total == 17 && !(soft && g.rules.dealerHitsSoft17)) {
+			return dealer
+		}
+		dealer = append(dealer, g.shoe.draw())
+	}
+}
+
+// simulationResult summarizes many rounds with an initial bet of 1
+type simulationResult struct {
+	rounds  int
+	wagered float64
+	net     float64
+	// the expected loss of the player per initial bet
+	houseEdge float64
+	// of the net result of a round
+	variance float64
+	stdDev   float64
+}
+
+// simulateBlackjack plays the rounds in parallel in batches, each batch has its own
+// shoe seeded from the batch and its own strategy, so the same seed always gives
+// the same result on any number of workers
+func simulateBlackjack(rules blackjackRules, newStrategy func() Strategy, rounds int, seed int64, workers int) (simulationResult, error) {
+	if err := rules.validate(); err != nil {
+		return simulationResult{}, err
+	}
+
+	type partial struct {
+		wagered, net, netSq float64
+		err                 error
+	}
+	partials := make([]partial, batchCount(rounds))
+
+	runBatches(rounds, seed, workers, func(b batch) {
+		g, _ := newBlackjackGame(rules, newStrategy(), rand.NewSource(b.seed))
+		p := &partials[b.index]
+		for i := 0; i < b.n; i++ {
+			r, err := g.playRound(1)
+			if err != nil {
+				p.err = err
+				return
+			}
+			p.wagered += r.wagered
+			p.net += r.net
+			p.netSq += r.net * r.net
+		}
+	})
+
+	// merged in order, the float sums don't depend on which batch finished first
+	result := simulationResult{rounds: rounds}
+	netSq := 0.0
+	for _, p := range partials {
+		if p.err != nil {
+			return simulationResult{}, p.err
+		}
+		result.wagered += p.wagered
+		result.net += p.net
+		netSq += p.netSq
+	}
+
+	if rounds > 0 {
+		n := float64(rounds)
+		mean := result.net / n
+		result.houseEdge = -mean
+		result.variance = netSq/n - mean*mean
+		result.stdDev = math.Sqrt(result.variance)
+	}
+	return result, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+// basicStrategy plays the standard chart for multi deck games,
+// a few plays change when the dealer hits soft 17 or the table
+// doesn't allow doubling after a split
+type basicStrategy struct {
+	hitsSoft17       bool
+	doubleAfterSplit bool
+}
+
+func newBasicStrategy(rules blackjackRules) Strategy {
+	return &basicStrategy{hitsSoft17: rules.dealerHitsSoft17, doubleAfterSplit: rules.doubleAfterSplit}
+}
+
+// insurance loses money unless the player counts cards
+func (b *basicStrategy) TakeInsurance(hand deck, dealerUp Card) bool {
+	return false
+}
+
+func (b *basicStrategy) Decide(s Situation) Action {
+	// the Ace is 11 in the chart
+	up := blackjackValue(s.DealerUp)
+	if up == 1 {
+		up = 11
+	}
+	total, soft := handTotal(s.Hand)
+
+	if s.CanSplit && b.split(blackjackValue(s.Hand[0]), up) {
+		return Split
+	}
+
+	if s.CanSurrender && !soft && b.surrender(total, up) {
+		return Surrender
+	}
+
+	if soft {
+		return b.soft(total, up, s.CanDouble)
+	}
+	return b.hard(total, up, s.CanDouble)
+}
+
+func (b *basicStrategy) split(value int, up int) bool {
+	switch value {
+	case 1, 8:
+		return true
+	case 9:
+		return up <= 9 && up != 7
+	case 7:
+		return up <= 7
+	case 6:
+		if b.doubleAfterSplit {
+			return up <= 6
+		}
+		return up >= 3 && up <= 6
+	case 4:
+		return b.doubleAfterSplit && (up == 5 || up == 6)
+	case 2, 3:
+		if b.doubleAfterSplit {
+			return up <= 7
+		}
+		return up >= 4 && up <= 7
+	}
+	return false
+}
+
+func (b *basicStrategy) surrender(total int, up int) bool {
+	switch total {
+	case 16:
+		return up >= 9
+	case 15:
+		return up == 10 || (b.hitsSoft17 && up == 11)
+	case 17:
+		return b.hitsSoft17 && up == 11
+	}
+	return false
+}
+
+func (b *basicStrategy) soft(total int, up int, canDouble bool) Action {
+	switch {
+	case total >= 20:
+		return Stand
+	case total == 19:
+		if b.hitsSoft17 && up == 6 {
+			return doubleOr(canDouble, Stand)
+		}
+		return Stand
+	case total == 18:
+		if up <= 6 && (up >= 3 || b.hitsSoft17) {
+			return doubleOr(canDouble, Stand)
+		}
+		if up <= 8 {
+			return Stand
+		}
+		return Hit
+	case total == 17:
+		if up >= 3 && up <= 6 {
+			return doubleOr(canDouble, Hit)
+		}
+	case total >= 15:
+		if up >= 4 && up <= 6 {
+			return doubleOr(canDouble, Hit)
+		}
+	default:
+		if up == 5 || up == 6 {
+			return doubleOr(canDouble, Hit)
+		}
+	}
+	return Hit
+}
+
+func (b *basicStrategy) hard(total int, up int, canDouble bool) Action {
+	switch {
+	case total >= 17:
+		return Stand
+	case total >= 13:
+		if up <= 6 {
+			return Stand
+		}
+	case total == 12:
+		if up >= 4 && up <= 6 {
+			return Stand
+		}
+	case total == 11:
+		if up != 11 || b.hitsSoft17 {
+			return doubleOr(canDouble, Hit)
+		}
+	case total == 10:
+		if up <= 9 {
+			return doubleOr(canDouble, Hit)
+		}
+	case total == 9:
+		if up >= 3 && up <= 6 {
+			return doubleOr(canDouble, Hit)
+		}
+	}
+	return Hit
+}
+
+func doubleOr(canDouble bool, otherwise Action) Action {
+	if canDouble {
+		return Double
+	}
+	return otherwise
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// scriptedStrategy plays the given actions in order and stands after them
+type scriptedStrategy struct {
+	actions   []Action
+	insurance bool
+}
+
+func (s *scriptedStrategy) Decide(Situation) Action {
+	if len(s.actions) == 0 {
+		return Stand
+	}
+	a := s.actions[0]
+	s.actions = s.actions[1:]
+	return a
+}
+
+func (s *scriptedStrategy) TakeInsurance(deck, Card) bool {
+	return s.insurance
+}
+
+// riggedGame deals the cards in order, player first and then the dealer
+func riggedGame(t *testing.T, rules blackjackRules, cards string, strategy Strategy) *blackjackGame {
+	d := mustParse(t, cards)
+	return &blackjackGame{
+		rules:    rules,
+		shoe:     &bjShoe{cards: d, cut: len(d), source: rand.NewSource(1)},
+		strategy: strategy,
+	}
+}
+
+func TestHandTotal(t *testing.T) {
+	tests := []struct {
+		hand  string
+		total int
+		soft  bool
+	}{
+		{"As Kd", 21, true},
+		{"As 6d", 17, true},
+		{"As 6d Th", 17, false},
+		{"As Ad", 12, true},
+		{"As Ad 9c", 21, true},
+		{"Ks Qd 5h", 25, false},
+		{"Ts 6d", 16, false},
+	}
+
+	for _, test := range tests {
+		total, soft := handTotal(mustParse(t, test.hand))
+		if total != test.total || soft != test.soft {
+			t.Errorf("Expected %v soft %v for %v, but got %v soft %v", test.total, test.soft, test.hand, total, soft)
+		}
+	}
+}
+
+func TestPlayRoundPayouts(t *testing.T) {
+	rules := defaultBlackjackRules()
+	tests := []struct {
+		name    string
+		cards   string
+		actions []Action
+		net     float64
+		wagered float64
+	}{
+		{"blackjack", "As 9d Kh 8c", nil, 1.5, 1},
+		{"push", "Ks Td Kh Jc", nil, 0, 1},
+		{"dealer blackjack", "Ks As Kh Jc", nil, -1, 1},
+		{"surrender", "Ts 9d 6h Kc", []Action{Surrender}, -0.5, 1},
+		{"double", "6s 9d 5h 8c Ts", []Action{Double}, 2, 2},
+		{"bust", "Ts 9d 6h 8c Ks", []Action{Hit}, -1, 1},
+		{"dealer bust", "Ts 6d 6h Tc Ks", nil, 1, 1},
+		// both eights get a ten and the dealer busts
+		{"split", "8s 6d 8h Tc Ts Th Kc", []Action{Split}, 2, 2},
+	}
+
+	for _, test := range tests {
+		g := riggedGame(t, rules, test.cards, &scriptedStrategy{actions: test.actions})
+		r, err := g.playRound(1)
+		if err != nil {
+			t.Errorf("Expected %v to be played, but got %v", test.name, err)
+			continue
+		}
+
+		if r.net != test.net || r.wagered != test.wagered {
+			t.Errorf("Expected %v to net %v of %v, but got %v of %v", test.name, test.net, test.wagered, r.net, r.wagered)
+		}
+	}
+}
+
+func TestDealerSoft17(t *testing.T) {
+	// the dealer has a soft 17 and the next card is a Four
+	cards := "Ts As Ks 6d 4c"
+
+	rules := defaultBlackjackRules()
+	r, _ := riggedGame(t, rules, cards, &scriptedStrategy{}).playRound(1)
+	if r.net != 1 {
+		t.Errorf("Expected S17 dealer to stand and lose, but got %v", r.net)
+	}
+
+	rules.dealerHitsSoft17 = true
+	r, _ = riggedGame(t, rules, cards, &scriptedStrategy{}).playRound(1)
+	if r.net != -1 {
+		t.Errorf("Expected H17 dealer to hit to 21 and win, but got %v", r.net)
+	}
+}
+
+func TestInsurance(t *testing.T) {
+	g := riggedGame(t, defaultBlackjackRules(), "Ts As Ks Kd", &scriptedStrategy{insurance: true})
+	r, _ := g.playRound(1)
+	if r.net != 0 || r.wagered != 1.5 {
+		t.Errorf("Expected insurance to cover the dealer blackjack, but got %v of %v", r.net, r.wagered)
+	}
+
+	g = riggedGame(t, defaultBlackjackRules(), "Ts As Ks 7d", &scriptedStrategy{insurance: true})
+	r, _ = g.playRound(1)
+	if r.net != 0.5 {
+		t.Errorf("Expected to lose the insurance and win the hand, but got %v", r.net)
+	}
+}
+
+func TestIllegalAction(t *testing.T) {
+	g := riggedGame(t, defaultBlackjackRules(), "Ts 9d 6h Kc", &scriptedStrategy{actions: []Action{Split}})
+	if _, err := g.playRound(1); err == nil {
+		t.Errorf("Expected an error splitting a ten and a six")
+	}
+}
+
+func TestBasicStrategy(t *testing.T) {
+	b := newBasicStrategy(defaultBlackjackRules())
+	tests := []struct {
+		hand   string
+		up     string
+		action Action
+	}{
+		{"Ts 6d", "Th", Surrender},
+		{"Ts 6d", "6h", Stand},
+		{"Ts 2d", "3h", Hit},
+		{"6s 5d", "6h", Double},
+		{"6s 5d", "Ah", Hit},
+		{"8s 8d", "Th", Split},
+		{"Ts Td", "6h", Stand},
+		{"As 7d", "9h", Hit},
+		{"As 7d", "4h", Double},
+		{"As 7d", "7h", Stand},
+		{"9s 9d", "7h", Stand},
+	}
+
+	for _, test := range tests {
+		hand := mustParse(t, test.hand)
+		s := Situation{
+			Hand:         hand,
+			DealerUp:     mustParse(t, test.up)[0],
+			Hands:        1,
+			CanDouble:    true,
+			CanSplit:     hand[0].Rank == hand[1].Rank,
+			CanSurrender: true,
+		}
+
+		if a := b.Decide(s); a != test.action {
+			t.Errorf("Expected %v with %v against %v, but got %v", test.action, test.hand, test.up, a)
+		}
+	}
+}
+
+func TestSimulateBlackjack(t *testing.T) {
+	rules := defaultBlackjackRules()
+	newStrategy := func() Strategy { return newBasicStrategy(rules) }
+
+	result, err := simulateBlackjack(rules, newStrategy, 200000, 1, 4)
+	if err != nil {
+		t.Fatalf("Expected the simulation to run, but got %v", err)
+	}
+
+	// basic strategy in this game has a house edge of about 0.4%,
+	// the standard error with this many rounds is about 0.26%
+	t.Logf("house edge %.4f std dev %.4f", result.houseEdge, result.stdDev)
+	if math.Abs(result.houseEdge-0.004) > 0.01 {
+		t.Errorf("Expected a house edge close to 0.4%%, but got %.4f", result.houseEdge)
+	}
+
+	if result.stdDev < 1 || result.stdDev > 1.3 {
+		t.Errorf("Expected a standard deviation of about 1.15, but got %.4f", result.stdDev)
+	}
+
+	// the number of workers doesn't change the result
+	again, _ := simulateBlackjack(rules, newStrategy, 200000, 1, 1)
+	if again != result {
+		t.Errorf("Expected the same seed to give the same result, but got %+v and %+v", result, again)
+	}
+}
+
+func BenchmarkPlayRound(b *testing.B) {
+	rules := defaultBlackjackRules()
+	g, _ := newBlackjackGame(rules, newBasicStrategy(rules), rand.NewSource(1))
+	for i := 0; i < b.N; i++ {
+		g.playRound(1)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidDeck is returned by deckBuilder.build for impossible compositions
+var ErrInvalidDeck = errors.New("invalid deck")
+
+// ranks of the common stripped decks, in the same order newDeck uses
+var (
+	piquetRanks  = []Rank{Ace, Seven, Eight, Nine, Ten, King, Queen, Jack}
+	euchreRanks  = []Rank{Ace, Nine, Ten, King, Queen, Jack}
+	spanishRanks = []Rank{Ace, Two, Three, Four, Five, Six, Seven, King, Queen, Jack}
+)
+
+// deckBuilder describes the composition of a deck,
+// the zero value is not useful, start from newDeckBuilder
+type deckBuilder struct {
+	ranks  []Rank
+	suits  []Suit
+	jokers int
+	decks  int
+}
+
+// newDeckBuilder starts from the standard 52 card deck
+func newDeckBuilder() *deckBuilder {
+	return &deckBuilder{ranks: cardRanks, suits: cardSuits, decks: 1}
+}
+
+// 32 cards, Seven to Ace
+func newPiquetBuilder() *deckBuilder {
+	return newDeckBuilder().withRanks(piquetRanks...)
+}
+
+// 24 cards, Nine to Ace
+func newEuchreBuilder() *deckBuilder {
+	return newDeckBuilder().withRanks(euchreRanks...)
+}
+
+// 40 cards, the Eights, Nines and Tens are removed
+func newSpanishBuilder() *deckBuilder {
+	return newDeckBuilder().withRanks(spanishRanks...)
+}
+
+// a casino shoe is a number of standard decks shuffled together
+func newShoeBuilder(decks int) *deckBuilder {
+	return newDeckBuilder().withDecks(decks)
+}
+
+func (b *deckBuilder) withRanks(ranks ...Rank) *deckBuilder {
+	b.ranks = ranks
+	return b
+}
+
+func (b *deckBuilder) withSuits(suits ...Suit) *deckBuilder {
+	b.suits = suits
+	return b
+}
+
+// withJokers adds up to two jokers to every deck, the black one first
+func (b *deckBuilder) withJokers(n int) *deckBuilder {
+	b.jokers = n
+	return b
+}
+
+func (b *deckBuilder) withDecks(n int) *deckBuilder {
+	b.decks = n
+	return b
+}
+
+func (b *deckBuilder) validate() error {
+	if b.decks < 1 {
+		return fmt.Errorf("%w: %d decks", ErrInvalidDeck, b.decks)
+	}
+
+	if b.jokers < 0 || b.jokers > 2 {
+		return fmt.Errorf("%w: %d jokers, a deck has between 0 and 2", ErrInvalidDeck, b.jokers)
+	}
+
+	if len(b.ranks) == 0 || len(b.suits) == 0 {
+		return fmt.Errorf("%w: no ranks or suits", ErrInvalidDeck)
+	}
+
+	ranks := map[Rank]bool{}
+	for _, r := range b.ranks {
+		if !r.valid() || r == Joker {
+			return fmt.Errorf("%w: unknown rank %v", ErrInvalidDeck, r)
+		}
+		if ranks[r] {
+			return fmt.Errorf("%w: rank %v is repeated", ErrInvalidDeck, r)
+		}
+		ranks[r] = true
+	}
+
+	suits := map[Suit]bool{}
+	for _, s := range b.suits {
+		if !s.valid() {
+			return fmt.Errorf("%w: unknown suit %v", ErrInvalidDeck, s)
+		}
+		if suits[s] {
+			return fmt.Errorf("%w: suit %v is repeated", ErrInvalidDeck, s)
+		}
+		suits[s] = true
+	}
+	return nil
+}
+
+// build returns the cards of every deck one after the other,
+// shuffle the result to mix the decks of a shoe
+func (b *deckBuilder) build() (deck, error) {
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+
+	jokers := []Card{BlackJoker, RedJoker}[:b.jokers]
+	cards := deck{}
+	for i := 0; i < b.decks; i++ {
+		for _, suit := range b.suits {
+			for _, rank := range b.ranks {
+				cards = append(cards, Card{Rank: rank, Suit: suit})
+			}
+		}
+		cards = append(cards, jokers...)
+	}
+	return cards, nil
+}
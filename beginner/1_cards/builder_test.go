@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeckBuilderSizes(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *deckBuilder
+		size    int
+	}{
+		{"standard", newDeckBuilder(), 52},
+		{"jokers", newDeckBuilder().withJokers(2), 54},
+		{"piquet", newPiquetBuilder(), 32},
+		{"euchre", newEuchreBuilder(), 24},
+		{"spanish", newSpanishBuilder(), 40},
+		{"shoe", newShoeBuilder(6), 312},
+		{"custom", newDeckBuilder().withRanks(Ace, King).withSuits(Hearts), 2},
+	}
+
+	for _, test := range tests {
+		d, err := test.builder.build()
+		if err != nil {
+			t.Errorf("Expected %v deck to be built, but got %v", test.name, err)
+			continue
+		}
+
+		if len(d) != test.size {
+			t.Errorf("Expected %v deck length of %v, but got %v", test.name, test.size, len(d))
+		}
+	}
+}
+
+func TestDeckBuilderStandardMatchesNewDeck(t *testing.T) {
+	d, _ := newDeckBuilder().build()
+	if d.toString() != newDeck().toString() {
+		t.Errorf("Expected the default builder to build newDeck")
+	}
+}
+
+func TestDeckBuilderInvalid(t *testing.T) {
+	tests := map[string]*deckBuilder{
+		"no decks":      newShoeBuilder(0),
+		"three jokers":  newDeckBuilder().withJokers(3),
+		"no ranks":      newDeckBuilder().withRanks(),
+		"no suits":      newDeckBuilder().withSuits(),
+		"repeated rank": newDeckBuilder().withRanks(Ace, Ace),
+		"repeated suit": newDeckBuilder().withSuits(Clubs, Clubs),
+		"joker rank":    newDeckBuilder().withRanks(Joker),
+		"unknown suit":  newDeckBuilder().withSuits(Suit(9)),
+	}
+
+	for name, b := range tests {
+		if _, err := b.build(); !errors.Is(err, ErrInvalidDeck) {
+			t.Errorf("Expected %v for %v, but got %v", ErrInvalidDeck, name, err)
+		}
+	}
+}
+
+func TestJokers(t *testing.T) {
+	if BlackJoker.String() != "Black Joker" || !RedJoker.IsRed() || !RedJoker.IsJoker() {
+		t.Errorf("Expected a black and a red joker, but got %v and %v", BlackJoker, RedJoker)
+	}
+
+	c, err := ParseCard("Red Joker")
+	if err != nil || c != RedJoker {
+		t.Errorf("Expected Red Joker to parse, but got %v and %v", c, err)
+	}
+
+	if _, err := ParseCard("Joker of Clubs"); err == nil {
+		t.Errorf("Expected an error parsing Joker of Clubs")
+	}
+}
+
+func TestShoeSaveAndLoadForEveryFormat(t *testing.T) {
+	dir := t.TempDir()
+	shoe, _ := newShoeBuilder(2).withJokers(2).build()
+	shoe.shuffleSeed(5)
+
+	for _, f := range deckFormats {
+		filename := filepath.Join(dir, "shoe_"+f.name())
+		if err := shoe.saveAs(filename, f.name()); err != nil {
+			t.Fatalf("Expected shoe to be saved as %v, but got %v", f.name(), err)
+		}
+
+		if _, err := newDeckFromFile(filename); !errors.Is(err, ErrDuplicateCard) {
+			t.Errorf("Expected a single deck load to reject a %v shoe, but got %v", f.name(), err)
+		}
+
+		loaded, err := newShoeFromFile(filename, 2)
+		if err != nil {
+			t.Fatalf("Expected %v shoe to be loaded, but got %v", f.name(), err)
+		}
+
+		if loaded.toString() != shoe.toString() {
+			t.Errorf("Expected the %v shoe to keep its order", f.name())
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rank is the value of a card, Ace is 1 and King is 13
+// the zero value is not a valid rank
+type Rank int
+
+const (
+	Ace Rank = iota + 1
+	Two
+	Three
+	Four
+	Five
+	Six
+	Seven
+	Eight
+	Nine
+	Ten
+	Jack
+	Queen
+	King
+	// Joker has no suit of its own, the suit only picks its color
+	Joker
+)
+
+// Suit is one of the four french suits
+// the zero value is not a valid suit
+type Suit int
+
+const (
+	Spades Suit = iota + 1
+	Diamonds
+	Hearts
+	Clubs
+)
+
+// Color of a suit, Spades and Clubs are black, Diamonds and Hearts are red
+type Color int
+
+const (
+	Black Color = iota
+	Red
+)
+
+// the order of these slices is the order used by newDeck
+var cardRanks = []Rank{Ace, Two, Three, Four, Five, Six, Seven, Eight, Nine, Ten, King, Queen, Jack}
+var cardSuits = []Suit{Spades, Diamonds, Hearts, Clubs}
+
+var rankNames = map[Rank]string{
+	Ace:   "Ace",
+	Two:   "Two",
+	Three: "Three",
+	Four:  "Four",
+	Five:  "Five",
+	Six:   "Six",
+	Seven: "Seven",
+	Eight: "Eight",
+	Nine:  "Nine",
+	Ten:   "Ten",
+	Jack:  "Jack",
+	Queen: "Queen",
+	King:  "King",
+	Joker: "Joker",
+}
+
+var suitNames = map[Suit]string{
+	Spades:   "Spades",
+	Diamonds: "Diamonds",
+	Hearts:   "Hearts",
+	Clubs:    "Clubs",
+}
+
+// Card is a single playing card like the Ace of Spades
+type Card struct {
+	Rank Rank
+	Suit Suit
+}
+
+// a deck has at most two jokers, a black one and a red one
+var (
+	BlackJoker = Card{Rank: Joker, Suit: Spades}
+	RedJoker   = Card{Rank: Joker, Suit: Hearts}
+)
+
+func (r Rank) String() string {
+	if name, ok := rankNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Rank(%d)", int(r))
+}
+
+// High is the value of the rank when the Ace is played high,
+// 2 for a Two up to 14 for an Ace, the Joker is above every rank
+func (r Rank) High() int {
+	switch r {
+	case Ace:
+		return int(King) + 1
+	case Joker:
+		return int(King) + 2
+	}
+	return int(r)
+}
+
+// Less orders ranks with the Ace above the King
+func (r Rank) Less(o Rank) bool {
+	return r.High() < o.High()
+}
+
+func (r Rank) valid() bool {
+	_, ok := rankNames[r]
+	return ok
+}
+
+func (s Suit) String() string {
+	if name, ok := suitNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Suit(%d)", int(s))
+}
+
+func (s Suit) Color() Color {
+	if s == Diamonds || s == Hearts {
+		return Red
+	}
+	return Black
+}
+
+func (s Suit) valid() bool {
+	_, ok := suitNames[s]
+	return ok
+}
+
+func (c Color) String() string {
+	if c == Red {
+		return "Red"
+	}
+	return "Black"
+}
+
+// String formats the card as "Value of Suit", for example "Ace of Spades",
+// jokers are formatted by color, "Black Joker" or "Red Joker"
+func (c Card) String() string {
+	if c.Rank == Joker {
+		return c.Color().String() + " " + c.Rank.String()
+	}
+	return c.Rank.String() + " of " + c.Suit.String()
+}
+
+func (c Card) Color() Color {
+	return c.Suit.Color()
+}
+
+func (c Card) IsRed() bool {
+	return c.Color() == Red
+}
+
+func (c Card) IsBlack() bool {
+	return c.Color() == Black
+}
+
+// IsFace reports whether the card is a Jack, Queen or King
+func (c Card) IsFace() bool {
+	return c.Rank == Jack || c.Rank == Queen || c.Rank == King
+}
+
+// Less orders cards by rank with the Ace high, and by suit when the ranks are equal
+func (c Card) Less(o Card) bool {
+	if c.Rank != o.Rank {
+		return c.Rank.Less(o.Rank)
+	}
+	return c.Suit < o.Suit
+}
+
+func (c Card) IsJoker() bool {
+	return c.Rank == Joker
+}
+
+func (c Card) valid() bool {
+	if c.Rank == Joker {
+		return c == BlackJoker || c == RedJoker
+	}
+	return c.Rank.valid() && c.Suit.valid()
+}
+
+// ParseRank converts a name like "Ace" back into a Rank
+func ParseRank(s string) (Rank, error) {
+	for r, name := range rankNames {
+		if name == s {
+			return r, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown rank %q", s)
+}
+
+// ParseSuit converts a name like "Spades" back into a Suit
+func ParseSuit(s string) (Suit, error) {
+	for suit, name := range suitNames {
+		if name == s {
+			return suit, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown suit %q", s)
+}
+
+// ParseCard is the inverse of Card.String, it reads "Value of Suit"
+func ParseCard(s string) (Card, error) {
+	for _, joker := range []Card{BlackJoker, RedJoker} {
+		if s == joker.String() {
+			return joker, nil
+		}
+	}
+
+	parts := strings.Split(s, " of ")
+	if len(parts) != 2 {
+		return Card{}, fmt.Errorf("invalid card %q", s)
+	}
+
+	r, err := ParseRank(parts[0])
+	if err != nil {
+		return Card{}, err
+	}
+
+	suit, err := ParseSuit(parts[1])
+	if err != nil {
+		return Card{}, err
+	}
+
+	// jokers are only read by their color, so every card prints back the way it was read
+	c := Card{Rank: r, Suit: suit}
+	if r == Joker || !c.valid() {
+		return Card{}, fmt.Errorf("invalid card %q", s)
+	}
+	return c, nil
+}
+
+// short names used by poker tools, "As" is the Ace of Spades and "Td" the Ten of Diamonds
+const shortRanks = "A23456789TJQK"
+const shortSuits = "sdhc"
+
+// Short formats the card in two letters, jokers are "Xb" and "Xr"
+func (c Card) Short() string {
+	if c.Rank == Joker {
+		return "X" + strings.ToLower(c.Color().String()[:1])
+	}
+	if !c.valid() {
+		return "??"
+	}
+	return string(shortRanks[c.Rank-1]) + string(shortSuits[c.Suit-1])
+}
+
+// ParseShortCard is the inverse of Card.Short
+func ParseShortCard(s string) (Card, error) {
+	for _, joker := range []Card{BlackJoker, RedJoker} {
+		if s == joker.Short() {
+			return joker, nil
+		}
+	}
+
+	if len(s) != 2 {
+		return Card{}, fmt.Errorf("invalid card %q", s)
+	}
+
+	r := strings.IndexByte(shortRanks, strings.ToUpper(s[:1])[0])
+	suit := strings.IndexByte(shortSuits, strings.ToLower(s[1:])[0])
+	if r < 0 || suit < 0 {
+		return Card{}, fmt.Errorf("invalid card %q", s)
+	}
+	return Card{Rank: Rank(r + 1), Suit: Suit(suit + 1)}, nil
+}
+
+// parseShortCards reads a list of short cards separated by spaces
+func parseShortCards(s string) (deck, error) {
+	cards := deck{}
+	for _, field := range strings.Fields(s) {
+		c, err := ParseShortCard(field)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, c)
+	}
+	return cards, nil
+}
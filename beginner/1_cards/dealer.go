@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// dealMode is the order in which cards are handed to the players
+type dealMode int
+
+const (
+	// one card to each player in turn until every hand is full
+	roundRobin dealMode = iota
+	// each player gets a full hand before the next one
+	inBlocks
+)
+
+// dealer owns the stock of cards that were not dealt yet
+// and the discard pile, so games don't need to track them
+type dealer struct {
+	stock    deck
+	discards deck
+	source   rand.Source
+	// reshuffle the discard pile into the stock when it runs out
+	reshuffle bool
+}
+
+// newDealer takes ownership of d, source is used when reshuffling
+// the discards and may be nil to seed from the current time
+func newDealer(d deck, source rand.Source) *dealer {
+	if source == nil {
+		source = rand.NewSource(time.Now().UnixNano())
+	}
+	return &dealer{stock: d, discards: deck{}, source: source}
+}
+
+// available is the number of cards that can still be drawn
+func (dl *dealer) available() int {
+	if dl.reshuffle {
+		return len(dl.stock) + len(dl.discards)
+	}
+	return len(dl.stock)
+}
+
+func (dl *dealer) ensure(n int) error {
+	if n < 0 || n > dl.available() {
+		return fmt.Errorf("%w: need %d, have %d", ErrNotEnoughCards, n, dl.available())
+	}
+	return nil
+}
+
+// draw takes n cards from the top of the stock, it deals nothing
+// when there are not enough cards
+func (dl *dealer) draw(n int) (deck, error) {
+	if err := dl.ensure(n); err != nil {
+		return nil, err
+	}
+
+	cards := deck{}
+	for len(cards) < n {
+		if len(dl.stock) == 0 {
+			dl.reshuffleDiscards()
+		}
+		cards = append(cards, dl.stock[0])
+		dl.stock = dl.stock[1:]
+	}
+	return cards, nil
+}
+
+// burn moves n cards from the top of the stock straight to the discard pile
+func (dl *dealer) burn(n int) error {
+	cards, err := dl.draw(n)
+	if err != nil {
+		return err
+	}
+	dl.discard(cards...)
+	return nil
+}
+
+// dealHands deals handSize cards to each of the players
+func (dl *dealer) dealHands(players int, handSize int, mode dealMode) ([]deck, error) {
+	if players < 1 {
+		return nil, fmt.Errorf("can't deal to %d players", players)
+	}
+
+	cards, err := dl.draw(players * handSize)
+	if err != nil {
+		return nil, err
+	}
+
+	hands := make([]deck, players)
+	for i := range hands {
+		hands[i] = deck{}
+	}
+
+	for i, card := range cards {
+		switch mode {
+		case inBlocks:
+			hands[i/handSize] = append(hands[i/handSize], card)
+		default:
+			hands[i%players] = append(hands[i%players], card)
+		}
+	}
+	return hands, nil
+}
+
+func (dl *dealer) discard(cards ...Card) {
+	dl.discards = append(dl.discards, cards...)
+}
+
+// reshuffleDiscards puts the discard pile under the stock and shuffles it
+func (dl *dealer) reshuffleDiscards() {
+	pile := dl.discards
+	pile.shuffleWith(dl.source)
+	dl.stock = append(dl.stock, pile...)
+	dl.discards = deck{}
+}
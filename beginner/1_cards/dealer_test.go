@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestDealNotEnoughCards(t *testing.T) {
+	_, remaining, err := deal(newDeck()[:3], 5)
+	if !errors.Is(err, ErrNotEnoughCards) {
+		t.Errorf("Expected %v, but got %v", ErrNotEnoughCards, err)
+	}
+
+	if len(remaining) != 3 {
+		t.Errorf("Expected the deck to be left alone, but got %v cards", len(remaining))
+	}
+}
+
+func TestDealHandsRoundRobin(t *testing.T) {
+	d := newDeck()
+	dl := newDealer(d, rand.NewSource(1))
+
+	hands, err := dl.dealHands(4, 5, roundRobin)
+	if err != nil {
+		t.Fatalf("Expected hands to be dealt, but got %v", err)
+	}
+
+	for p, hand := range hands {
+		if len(hand) != 5 {
+			t.Errorf("Expected hand length of 5, but got %v", len(hand))
+		}
+		if hand[1] != d[p+4] {
+			t.Errorf("Expected player %v second card to be %v, but got %v", p, d[p+4], hand[1])
+		}
+	}
+
+	if dl.available() != 32 {
+		t.Errorf("Expected 32 cards left, but got %v", dl.available())
+	}
+}
+
+func TestDealHandsInBlocks(t *testing.T) {
+	d := newDeck()
+	dl := newDealer(d, rand.NewSource(1))
+
+	hands, err := dl.dealHands(3, 4, inBlocks)
+	if err != nil {
+		t.Fatalf("Expected hands to be dealt, but got %v", err)
+	}
+
+	if hands[1][0] != d[4] || hands[2][3] != d[11] {
+		t.Errorf("Expected consecutive cards for each player, but got %v", hands)
+	}
+}
+
+func TestDealHandsNotEnoughCards(t *testing.T) {
+	dl := newDealer(newDeck(), rand.NewSource(1))
+
+	if _, err := dl.dealHands(11, 5, roundRobin); !errors.Is(err, ErrNotEnoughCards) {
+		t.Errorf("Expected %v, but got %v", ErrNotEnoughCards, err)
+	}
+
+	if dl.available() != 52 {
+		t.Errorf("Expected no cards to be dealt, but %v are left", dl.available())
+	}
+}
+
+func TestBurnAndDiscard(t *testing.T) {
+	dl := newDealer(newDeck(), rand.NewSource(1))
+
+	if err := dl.burn(1); err != nil {
+		t.Fatalf("Expected a card to be burned, but got %v", err)
+	}
+
+	hand, _ := dl.draw(2)
+	dl.discard(hand...)
+
+	if len(dl.discards) != 3 || len(dl.stock) != 49 {
+		t.Errorf("Expected 3 discards and 49 cards in stock, but got %v and %v", len(dl.discards), len(dl.stock))
+	}
+}
+
+func TestReshuffleDiscards(t *testing.T) {
+	dl := newDealer(newDeck()[:6], rand.NewSource(1))
+	dl.reshuffle = true
+
+	hand, _ := dl.draw(4)
+	dl.discard(hand...)
+
+	// 2 cards are left in the stock and 4 in the discard pile
+	cards, err := dl.draw(5)
+	if err != nil {
+		t.Fatalf("Expected discards to be reshuffled, but got %v", err)
+	}
+
+	if len(cards) != 5 || dl.available() != 1 {
+		t.Errorf("Expected 5 cards and 1 left, but got %v and %v", len(cards), dl.available())
+	}
+
+	if _, err := dl.draw(2); !errors.Is(err, ErrNotEnoughCards) {
+		t.Errorf("Expected %v, but got %v", ErrNotEnoughCards, err)
+	}
+}
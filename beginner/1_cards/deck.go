@@ -5,23 +5,22 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 )
 
 // Create a new type of 'deck'
-// which is a slice of strings
-type deck []string
+// which is a slice of cards
+type deck []Card
 
 // functions must have return value type after the arguments
 func newDeck() deck {
 	cards := deck{}
-	cardSuits := []string{"Spades", "Diamonds", "Hearts", "Clubs"}
-	cardValues := []string{"Ace", "Two", "Three", "Four", "Five", "Six", "Seven", "Eight", "Nine", "Ten", "King", "Queen", "Jack"}
 
-	for _, suits := range cardSuits {
-		for _, value := range cardValues {
-			cards = append(cards, value+" of "+suits)
+	for _, suit := range cardSuits {
+		for _, rank := range cardRanks {
+			cards = append(cards, Card{Rank: rank, Suit: suit})
 		}
 	}
 
@@ -39,38 +38,133 @@ func (d deck) print() {
 
 // we can use slices like on python
 // functions can return multiple values
-func deal(d deck, handSize int) (deck, deck) {
-	return d[0:handSize], d[handSize:]
+func deal(d deck, handSize int) (deck, deck, error) {
+	if handSize < 0 || handSize > len(d) {
+		return nil, d, fmt.Errorf("%w: need %d, have %d", ErrNotEnoughCards, handSize, len(d))
+	}
+	return d[0:handSize], d[handSize:], nil
 }
 
 // we can convert types by using <type>(<var>)
 func (d deck) toString() string {
-	return strings.Join([]string(d), ",")
+	names := []string{}
+	for _, card := range d {
+		names = append(names, card.String())
+	}
+	return strings.Join(names, ",")
 }
 
+// saveToFile picks the format from the file extension and writes the deck
+// to a temporary file next to filename before renaming it,
+// so a crash can never leave a truncated deck behind
 func (d deck) saveToFile(filename string) error {
-	return ioutil.WriteFile(filename, []byte(d.toString()), 0666)
+	return d.saveAs(filename, formatForFile(filename))
 }
 
-func newDeckFromFile(filename string) deck {
-	bs, err := ioutil.ReadFile(filename)
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
 	if err != nil {
-		// log the error and return a call to newDeck()
-		// or log the error and entirely quit the program
-		fmt.Println("Error:", err)
-		os.Exit(1)
+		return err
 	}
+	tmp := f.Name()
 
-	// convert the slice of strings to a deck
-	return deck(strings.Split(string(bs), ","))
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	// make sure the data is on disk before the rename makes it visible
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// instead of quitting the program we return the error
+// and let the caller decide what to do with it,
+// the format of the file is detected from its contents
+func newDeckFromFile(filename string) (deck, error) {
+	return loadDeck(filename, 1)
 }
 
+// newShoeFromFile loads a shoe saved from a deckBuilder,
+// each card may appear once for every deck in the shoe
+func newShoeFromFile(filename string, decks int) (deck, error) {
+	return loadDeck(filename, decks)
+}
+
+// newDeckFromString is the inverse of toString, every entry must be
+// a known card that appears only once
+func newDeckFromString(s string) (deck, error) {
+	cards, err := splitDeckString(s)
+	if err != nil {
+		return nil, err
+	}
+	if err := cards.validate(1); err != nil {
+		return nil, err
+	}
+	return cards, nil
+}
+
+// splitDeckString parses the entries without checking for duplicates
+func splitDeckString(s string) (deck, error) {
+	cards := deck{}
+	if s == "" {
+		return cards, nil
+	}
+
+	entries := strings.Split(s, ",")
+	last := len(entries) - 1
+	if strings.HasSuffix(entries[last], "\n") {
+		return nil, &DeckError{Pos: last, Entry: entries[last], Err: ErrTrailingNewline}
+	}
+
+	for i, entry := range entries {
+		card, err := parseEntry(i, entry)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+// shuffle uses a source seeded with the current time,
+// use shuffleSeed or shuffleWith when the result must be reproducible
 func (d deck) shuffle() {
-	source := rand.NewSource(time.Now().UnixNano())
+	d.shuffleWith(rand.NewSource(time.Now().UnixNano()))
+}
+
+func (d deck) shuffleSeed(seed int64) {
+	d.shuffleWith(rand.NewSource(seed))
+}
+
+// shuffleWith is a Fisher-Yates shuffle, every card can be swapped
+// with any card that was not placed yet, including itself,
+// so all the permutations have the same probability
+func (d deck) shuffleWith(source rand.Source) {
 	r := rand.New(source)
 
-	for i := range d {
-		newPos := r.Intn(len(d) - 1) // len() gets the length of a slice
+	for i := len(d) - 1; i > 0; i-- {
+		newPos := r.Intn(i + 1) // a position from 0 to i, i included
 		d[i], d[newPos] = d[newPos], d[i]
 	}
 }
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"errors"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -12,11 +15,11 @@ func TestNewDeck(t *testing.T) {
 		t.Errorf("Expected deck length of 52, but got %v", len(d))
 	}
 
-	if d[0] != "Ace of Spades" {
+	if d[0] != (Card{Rank: Ace, Suit: Spades}) {
 		t.Errorf("Expected first card of Ace of Spades, but got %v", d[0])
 	}
 
-	if d[len(d)-1] != "Jack of Clubs" {
+	if d[len(d)-1] != (Card{Rank: Jack, Suit: Clubs}) {
 		t.Errorf("Expected first card of Jack of Clubs, but got %v", d[len(d)-1])
 	}
 }
@@ -26,9 +29,14 @@ func TestSaveToDeckAndNewDeckFromFile(t *testing.T) {
 	os.Remove(filename)
 
 	d := newDeck()
-	d.saveToFile(filename)
+	if err := d.saveToFile(filename); err != nil {
+		t.Fatalf("Expected deck to be saved, but got %v", err)
+	}
 
-	loadedDeck := newDeckFromFile(filename)
+	loadedDeck, err := newDeckFromFile(filename)
+	if err != nil {
+		t.Fatalf("Expected deck to be loaded, but got %v", err)
+	}
 
 	if len(loadedDeck) != 52 {
 		t.Errorf("Expected deck length of 52, but got %v", len(loadedDeck))
@@ -36,3 +44,132 @@ func TestSaveToDeckAndNewDeckFromFile(t *testing.T) {
 
 	os.Remove(filename)
 }
+
+func TestCardString(t *testing.T) {
+	c := Card{Rank: Queen, Suit: Hearts}
+
+	if c.String() != "Queen of Hearts" {
+		t.Errorf("Expected Queen of Hearts, but got %v", c.String())
+	}
+}
+
+func TestParseCard(t *testing.T) {
+	for _, c := range newDeck() {
+		parsed, err := ParseCard(c.String())
+		if err != nil {
+			t.Errorf("Expected %v to parse, but got %v", c, err)
+		}
+		if parsed != c {
+			t.Errorf("Expected %v, but got %v", c, parsed)
+		}
+	}
+
+	for _, s := range []string{"", "Ace", "Ace of", "One of Spades", "Ace of Stars", "Ace of Spades of Hearts", "Joker of Spades", "Joker of Hearts"} {
+		if _, err := ParseCard(s); err == nil {
+			t.Errorf("Expected an error parsing %q", s)
+		}
+	}
+}
+
+func TestCardPredicates(t *testing.T) {
+	d := newDeck()
+	reds, faces := 0, 0
+	for _, c := range d {
+		if c.IsRed() {
+			reds++
+		}
+		if c.IsFace() {
+			faces++
+		}
+	}
+
+	if reds != 26 {
+		t.Errorf("Expected 26 red cards, but got %v", reds)
+	}
+
+	if faces != 12 {
+		t.Errorf("Expected 12 face cards, but got %v", faces)
+	}
+}
+
+func TestRankOrdering(t *testing.T) {
+	if !King.Less(Ace) {
+		t.Errorf("Expected King to rank below Ace")
+	}
+
+	if !Two.Less(Three) || Three.Less(Two) {
+		t.Errorf("Expected Two to rank below Three")
+	}
+
+	if !(Card{Rank: Ten, Suit: Clubs}).Less(Card{Rank: Jack, Suit: Spades}) {
+		t.Errorf("Expected Ten of Clubs to be less than Jack of Spades")
+	}
+}
+
+func TestNewDeckFromFileMissing(t *testing.T) {
+	_, err := newDeckFromFile(filepath.Join(t.TempDir(), "missing"))
+	if !os.IsNotExist(err) {
+		t.Errorf("Expected a not exist error, but got %v", err)
+	}
+}
+
+func TestNewDeckFromStringErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   error
+		pos   int
+		entry string
+	}{
+		{"Ace of Spades,One of Spades", ErrUnknownCard, 1, "One of Spades"},
+		{"Ace of Spades,Two of Spades,Ace of Spades", ErrDuplicateCard, 2, "Ace of Spades"},
+		{"Ace of Spades,,Two of Spades", ErrEmptyEntry, 1, ""},
+		{"Ace of Spades,", ErrEmptyEntry, 1, ""},
+		{"Ace of Spades,Two of Spades\n", ErrTrailingNewline, 1, "Two of Spades\n"},
+	}
+
+	for _, test := range tests {
+		_, err := newDeckFromString(test.input)
+		if !errors.Is(err, test.err) {
+			t.Errorf("Expected %v for %q, but got %v", test.err, test.input, err)
+			continue
+		}
+
+		var deckErr *DeckError
+		if !errors.As(err, &deckErr) {
+			t.Errorf("Expected a DeckError for %q, but got %T", test.input, err)
+			continue
+		}
+
+		if deckErr.Pos != test.pos || deckErr.Entry != test.entry {
+			t.Errorf("Expected entry %v %q, but got %v %q", test.pos, test.entry, deckErr.Pos, deckErr.Entry)
+		}
+	}
+}
+
+func TestSaveToFileReplacesAtomically(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "cards")
+
+	if err := newDeck().saveToFile(filename); err != nil {
+		t.Fatalf("Expected deck to be saved, but got %v", err)
+	}
+
+	hand := newDeck()[:5]
+	if err := hand.saveToFile(filename); err != nil {
+		t.Fatalf("Expected deck to be saved, but got %v", err)
+	}
+
+	loaded, err := newDeckFromFile(filename)
+	if err != nil {
+		t.Fatalf("Expected deck to be loaded, but got %v", err)
+	}
+
+	if len(loaded) != 5 {
+		t.Errorf("Expected deck length of 5, but got %v", len(loaded))
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("Expected no temporary files to be left, but got %v files", len(files))
+	}
+}
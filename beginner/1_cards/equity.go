@@ -0,0 +1,342 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+)
+
+// pokerGame selects how the hole cards and the board make a hand
+type pokerGame int
+
+const (
+	// 2 hole cards, the best 5 of the 7 cards
+	holdem pokerGame = iota
+	// 4 hole cards, exactly 2 of them and 3 of the board
+	omaha
+)
+
+func (g pokerGame) holeCards() int {
+	if g == omaha {
+		return 4
+	}
+	return 2
+}
+
+const (
+	defaultIterations = 100000
+	defaultExactLimit = 100000
+	boardSize         = 5
+	// the z score of a 95% confidence interval
+	z95 = 1.96
+)
+
+// equityConfig describes a spot, the zero values of the numbers pick the defaults
+type equityConfig struct {
+	game  pokerGame
+	hands []deck
+	board deck
+	// cards that are known to be out of the stock, like folded hands
+	dead deck
+	// number of boards sampled by the Monte Carlo simulation
+	iterations int
+	seed       int64
+	workers    int
+	// boards are enumerated exactly when there are at most this many of them
+	exactLimit int
+}
+
+// interval is a 95% confidence interval
+type interval struct {
+	low, high float64
+}
+
+// playerEquity holds the probabilities of a single player, equity counts
+// a win as 1 and a tie between k players as 1/k
+type playerEquity struct {
+	win, tie, loss, equity         float64
+	winCI, tieCI, lossCI, equityCI interval
+}
+
+type equityResult struct {
+	players []playerEquity
+	// number of boards evaluated
+	boards int
+	// exact results have no sampling error
+	exact bool
+}
+
+// equityCounts accumulates the outcomes of the boards of one worker
+type equityCounts struct {
+	wins, ties, losses []int
+	equity, equitySq   []float64
+	boards             int
+}
+
+func newEquityCounts(players int) *equityCounts {
+	return &equityCounts{
+		wins:     make([]int, players),
+		ties:     make([]int, players),
+		losses:   make([]int, players),
+		equity:   make([]float64, players),
+		equitySq: make([]float64, players),
+	}
+}
+
+func (c *equityCounts) merge(o *equityCounts) {
+	for i := range c.wins {
+		c.wins[i] += o.wins[i]
+		c.ties[i] += o.ties[i]
+		c.losses[i] += o.losses[i]
+		c.equity[i] += o.equity[i]
+		c.equitySq[i] += o.equitySq[i]
+	}
+	c.boards += o.boards
+}
+
+// evaluator scores every player on a complete board, each worker owns one
+// so the buffers are never shared between goroutines
+type evaluator struct {
+	game   pokerGame
+	hands  []deck
+	values []handValue
+	cards  deck
+}
+
+func newEvaluator(game pokerGame, hands []deck) *evaluator {
+	return &evaluator{
+		game:   game,
+		hands:  hands,
+		values: make([]handValue, len(hands)),
+		cards:  make(deck, 0, 9),
+	}
+}
+
+func (e *evaluator) record(board deck, c *equityCounts) {
+	best := handValue(0)
+	for i, hand := range e.hands {
+		if e.game == omaha {
+			e.values[i] = e.omahaValue(hand, board)
+		} else {
+			e.cards = append(append(e.cards[:0], hand...), board...)
+			e.values[i] = evaluate(e.cards)
+		}
+		if e.values[i] > best {
+			best = e.values[i]
+		}
+	}
+
+	winners := 0
+	for _, v := range e.values {
+		if v == best {
+			winners++
+		}
+	}
+
+	share := 1 / float64(winners)
+	for i, v := range e.values {
+		switch {
+		case v != best:
+			c.losses[i]++
+			continue
+		case winners == 1:
+			c.wins[i]++
+		default:
+			c.ties[i]++
+		}
+		c.equity[i] += share
+		c.equitySq[i] += share * share
+	}
+	c.boards++
+}
+
+// omahaValue uses exactly two hole cards and three board cards
+func (e *evaluator) omahaValue(hand deck, board deck) handValue {
+	best := handValue(0)
+	five := e.cards[:5]
+	forEachCombination(len(hand), 2, func(h []int) {
+		five[0], five[1] = hand[h[0]], hand[h[1]]
+		forEachCombination(len(board), 3, func(b []int) {
+			five[2], five[3], five[4] = board[b[0]], board[b[1]], board[b[2]]
+			if v := evaluate(five); v > best {
+				best = v
+			}
+		})
+	})
+	return best
+}
+
+func (cfg *equityConfig) validate() (deck, error) {
+	if len(cfg.hands) < 2 || len(cfg.hands) > 10 {
+		return nil, fmt.Errorf("equity needs 2 to 10 players, got %d", len(cfg.hands))
+	}
+
+	if len(cfg.board) > boardSize {
+		return nil, fmt.Errorf("the board has at most %d cards, got %d", boardSize, len(cfg.board))
+	}
+
+	known := map[Card]bool{}
+	check := func(cards deck) error {
+		for _, c := range cards {
+			if !pokerCard(c) {
+				return fmt.Errorf("%w: %v", ErrUnknownCard, c)
+			}
+			if known[c] {
+				return fmt.Errorf("%w: %v", ErrDuplicateCard, c)
+			}
+			known[c] = true
+		}
+		return nil
+	}
+
+	for i, hand := range cfg.hands {
+		if len(hand) != cfg.game.holeCards() {
+			return nil, fmt.Errorf("player %d needs %d hole cards, got %d", i, cfg.game.holeCards(), len(hand))
+		}
+		if err := check(hand); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := check(cfg.board); err != nil {
+		return nil, err
+	}
+	if err := check(cfg.dead); err != nil {
+		return nil, err
+	}
+
+	stock := deck{}
+	for _, c := range newDeck() {
+		if !known[c] {
+			stock = append(stock, c)
+		}
+	}
+
+	if len(stock) < boardSize-len(cfg.board) {
+		return nil, fmt.Errorf("%w to complete the board", ErrNotEnoughCards)
+	}
+	return stock, nil
+}
+
+// calculateEquity enumerates every board when there are few of them
+// and samples boards in parallel otherwise
+func calculateEquity(cfg equityConfig) (equityResult, error) {
+	stock, err := cfg.validate()
+	if err != nil {
+		return equityResult{}, err
+	}
+
+	if cfg.iterations <= 0 {
+		cfg.iterations = defaultIterations
+	}
+	if cfg.workers <= 0 {
+		cfg.workers = runtime.NumCPU()
+	}
+	if cfg.exactLimit <= 0 {
+		cfg.exactLimit = defaultExactLimit
+	}
+
+	missing := boardSize - len(cfg.board)
+	if binomial(len(stock), missing) <= float64(cfg.exactLimit) {
+		return newEquityResult(enumerateBoards(cfg, stock, missing), true), nil
+	}
+	return newEquityResult(sampleBoards(cfg, stock, missing), false), nil
+}
+
+func enumerateBoards(cfg equityConfig, stock deck, missing int) *equityCounts {
+	counts := newEquityCounts(len(cfg.hands))
+	e := newEvaluator(cfg.game, cfg.hands)
+	board := append(deck{}, cfg.board...)
+	full := board[:len(board):len(board)]
+
+	forEachCombination(len(stock), missing, func(idx []int) {
+		full = full[:len(board)]
+		for _, i := range idx {
+			full = append(full, stock[i])
+		}
+		e.record(full, counts)
+	})
+	return counts
+}
+
+// sampleBoards samples the boards in batches, each batch has its own seed
+// so the same config always gives the same result on any number of workers
+func sampleBoards(cfg equityConfig, stock deck, missing int) *equityCounts {
+	results := make([]*equityCounts, batchCount(cfg.iterations))
+
+	runBatches(cfg.iterations, cfg.seed, cfg.workers, func(b batch) {
+		r := rand.New(rand.NewSource(b.seed))
+		counts := newEquityCounts(len(cfg.hands))
+		e := newEvaluator(cfg.game, cfg.hands)
+		cards := append(deck{}, stock...)
+		board := append(deck{}, cfg.board...)
+
+		for i := 0; i < b.n; i++ {
+			// a partial Fisher-Yates shuffle only needs to place the missing cards
+			for j := 0; j < missing; j++ {
+				k := j + r.Intn(len(cards)-j)
+				cards[j], cards[k] = cards[k], cards[j]
+			}
+			e.record(append(board, cards[:missing]...), counts)
+		}
+		results[b.index] = counts
+	})
+
+	// merged in order, the float sums don't depend on which batch finished first
+	total := newEquityCounts(len(cfg.hands))
+	for _, counts := range results {
+		total.merge(counts)
+	}
+	return total
+}
+
+func newEquityResult(c *equityCounts, exact bool) equityResult {
+	result := equityResult{boards: c.boards, exact: exact}
+	n := float64(c.boards)
+
+	for i := range c.wins {
+		p := playerEquity{
+			win:    float64(c.wins[i]) / n,
+			tie:    float64(c.ties[i]) / n,
+			loss:   float64(c.losses[i]) / n,
+			equity: c.equity[i] / n,
+		}
+
+		if exact {
+			p.winCI = interval{p.win, p.win}
+			p.tieCI = interval{p.tie, p.tie}
+			p.lossCI = interval{p.loss, p.loss}
+			p.equityCI = interval{p.equity, p.equity}
+		} else {
+			p.winCI = proportionInterval(p.win, n)
+			p.tieCI = proportionInterval(p.tie, n)
+			p.lossCI = proportionInterval(p.loss, n)
+			variance := c.equitySq[i]/n - p.equity*p.equity
+			p.equityCI = meanInterval(p.equity, math.Max(variance, 0), n)
+		}
+		result.players = append(result.players, p)
+	}
+	return result
+}
+
+func proportionInterval(p float64, n float64) interval {
+	return meanInterval(p, p*(1-p), n)
+}
+
+func meanInterval(mean float64, variance float64, n float64) interval {
+	margin := z95 * math.Sqrt(variance/n)
+	return interval{math.Max(mean-margin, 0), math.Min(mean+margin, 1)}
+}
+
+// binomial is n choose k as a float so large values don't overflow
+func binomial(n int, k int) float64 {
+	if k < 0 || k > n {
+		return 0
+	}
+	result := 1.0
+	for i := 0; i < k; i++ {
+		result = result * float64(n-i) / float64(i+1)
+	}
+	return result
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func hands(t *testing.T, ss ...string) []deck {
+	result := []deck{}
+	for _, s := range ss {
+		result = append(result, mustParse(t, s))
+	}
+	return result
+}
+
+func TestEquityPreflopAcesAgainstKings(t *testing.T) {
+	result, err := calculateEquity(equityConfig{
+		hands:      hands(t, "As Ah", "Ks Kh"),
+		iterations: 200000,
+		seed:       1,
+	})
+	if err != nil {
+		t.Fatalf("Expected equity, but got %v", err)
+	}
+
+	if result.exact {
+		t.Errorf("Expected a preflop spot to be sampled")
+	}
+
+	// the known equity of AA against KK is about 82%
+	aces := result.players[0]
+	if math.Abs(aces.equity-0.82) > 0.01 {
+		t.Errorf("Expected aces to have about 82%% equity, but got %.4f", aces.equity)
+	}
+
+	if aces.equityCI.low > aces.equity || aces.equityCI.high < aces.equity || aces.equityCI.high-aces.equityCI.low > 0.01 {
+		t.Errorf("Expected a narrow interval around the equity, but got %+v", aces.equityCI)
+	}
+
+	kings := result.players[1]
+	if math.Abs(aces.win+aces.tie+aces.loss-1) > 1e-9 || math.Abs(aces.win-kings.loss) > 1e-9 {
+		t.Errorf("Expected the probabilities to add up, but got %+v and %+v", aces, kings)
+	}
+}
+
+func TestEquityIsReproducible(t *testing.T) {
+	cfg := equityConfig{
+		hands:      hands(t, "As Kd", "7h 7c", "Qs Js"),
+		iterations: 20000,
+		seed:       42,
+		workers:    4,
+	}
+
+	a, _ := calculateEquity(cfg)
+	// the number of workers doesn't change the result
+	cfg.workers = 1
+	b, _ := calculateEquity(cfg)
+	for i := range a.players {
+		if a.players[i] != b.players[i] {
+			t.Errorf("Expected the same seed to give the same result, but got %+v and %+v", a.players[i], b.players[i])
+		}
+	}
+}
+
+func TestEquityExactOnTheTurn(t *testing.T) {
+	// the flush draw has 9 outs out of the 44 river cards
+	result, err := calculateEquity(equityConfig{
+		hands: hands(t, "Ah Kh", "As Ac"),
+		board: mustParse(t, "2h 7h Qc 3d"),
+	})
+	if err != nil {
+		t.Fatalf("Expected equity, but got %v", err)
+	}
+
+	if !result.exact || result.boards != 44 {
+		t.Errorf("Expected 44 boards to be enumerated, but got %v", result.boards)
+	}
+
+	if math.Abs(result.players[0].win-9.0/44) > 1e-9 {
+		t.Errorf("Expected a win probability of 9/44, but got %.4f", result.players[0].win)
+	}
+}
+
+func TestEquityTies(t *testing.T) {
+	result, _ := calculateEquity(equityConfig{
+		hands: hands(t, "2c 3d", "2h 3s"),
+		board: mustParse(t, "As Ks Qs Js Ts"),
+	})
+
+	for _, p := range result.players {
+		if p.tie != 1 || p.equity != 0.5 {
+			t.Errorf("Expected the board to be split, but got %+v", p)
+		}
+	}
+}
+
+func TestEquityOmaha(t *testing.T) {
+	// the board has five hearts but omaha needs two hole cards of the suit,
+	// so the ace of hearts makes no flush and 7 8 makes a straight with 9 T J
+	result, err := calculateEquity(equityConfig{
+		game:  omaha,
+		hands: hands(t, "Ah 2c 3d 4s", "Kc Kd 7s 8s"),
+		board: mustParse(t, "5h 9h Th Jh 2h"),
+	})
+	if err != nil {
+		t.Fatalf("Expected equity, but got %v", err)
+	}
+
+	if result.players[1].win != 1 {
+		t.Errorf("Expected the straight to win, but got %+v", result.players[1])
+	}
+}
+
+func TestEquityInvalidConfig(t *testing.T) {
+	tests := map[string]equityConfig{
+		"one player":     {hands: hands(t, "As Ah")},
+		"duplicate card": {hands: hands(t, "As Ah", "As Kh")},
+		"dead card":      {hands: hands(t, "As Ah", "Ks Kh"), dead: mustParse(t, "Ah")},
+		"omaha hand":     {game: omaha, hands: hands(t, "As Ah", "Ks Kh")},
+		"long board":     {hands: hands(t, "As Ah", "Ks Kh"), board: mustParse(t, "2c 3c 4c 5c 6c 7c")},
+		"joker":          {hands: []deck{{BlackJoker, RedJoker}, mustParse(t, "Ks Kh")}},
+	}
+
+	for name, cfg := range tests {
+		if _, err := calculateEquity(cfg); err == nil {
+			t.Errorf("Expected an error for %v", name)
+		}
+	}
+
+	_, err := calculateEquity(equityConfig{hands: hands(t, "As Ah", "As Kh")})
+	if !errors.Is(err, ErrDuplicateCard) {
+		t.Errorf("Expected %v, but got %v", ErrDuplicateCard, err)
+	}
+}
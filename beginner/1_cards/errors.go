@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errors returned inside a DeckError, check them with errors.Is
+var (
+	ErrUnknownCard     = errors.New("unknown card")
+	ErrDuplicateCard   = errors.New("duplicate card")
+	ErrEmptyEntry      = errors.New("empty entry")
+	ErrTrailingNewline = errors.New("trailing newline")
+)
+
+// ErrNotEnoughCards is returned when a deal needs more cards than are left
+var ErrNotEnoughCards = errors.New("not enough cards")
+
+// DeckError is returned when a saved deck has an invalid entry,
+// Pos is the index of the entry in the file starting at 0
+type DeckError struct {
+	Pos   int
+	Entry string
+	Err   error
+}
+
+func (e *DeckError) Error() string {
+	return fmt.Sprintf("invalid deck entry %d %q: %v", e.Pos, e.Entry, e.Err)
+}
+
+func (e *DeckError) Unwrap() error {
+	return e.Err
+}
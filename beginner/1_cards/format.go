@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// deckFormat is implemented by every way we know to write a deck to a file
+// to support a new format we add a type and register it, saveToFile and
+// newDeckFromFile don't need to change
+type deckFormat interface {
+	name() string
+	// detect reports whether the bytes look like they were written by this format
+	detect(bs []byte) bool
+	encode(d deck) ([]byte, error)
+	decode(bs []byte) (deck, error)
+}
+
+// formats are detected in this order, the comma format detects anything
+// so it must be the last one
+var deckFormats = []deckFormat{}
+
+// file extensions that select a format when saving
+var formatExtensions = map[string]string{}
+
+func init() {
+	registerFormat(binaryFormat{}, ".bin", ".deck")
+	registerFormat(jsonFormat{}, ".json")
+	registerFormat(csvFormat{}, ".csv")
+	registerFormat(linesFormat{}, ".txt")
+	registerFormat(commaFormat{})
+}
+
+func registerFormat(f deckFormat, extensions ...string) {
+	deckFormats = append(deckFormats, f)
+	for _, ext := range extensions {
+		formatExtensions[ext] = f.name()
+	}
+}
+
+func formatByName(name string) (deckFormat, error) {
+	for _, f := range deckFormats {
+		if f.name() == name {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown deck format %q", name)
+}
+
+// formatForFile picks the format from the file extension,
+// files without a known extension use the original comma format
+func formatForFile(filename string) string {
+	if name, ok := formatExtensions[strings.ToLower(filepath.Ext(filename))]; ok {
+		return name
+	}
+	return commaFormat{}.name()
+}
+
+// detectFileFormat is detectFormat with the extension of the file
+// to tell a lines file with a single card from a comma file
+func detectFileFormat(filename string, bs []byte) deckFormat {
+	lines := linesFormat{}
+	if formatForFile(filename) == lines.name() && lines.singleLine(bs) {
+		return lines
+	}
+	return detectFormat(bs)
+}
+
+func detectFormat(bs []byte) deckFormat {
+	for _, f := range deckFormats {
+		if f.detect(bs) {
+			return f
+		}
+	}
+	return commaFormat{}
+}
+
+// saveAs writes the deck using the format with the given name
+func (d deck) saveAs(filename string, format string) error {
+	f, err := formatByName(format)
+	if err != nil {
+		return err
+	}
+
+	bs, err := f.encode(d)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filename, bs, 0644)
+}
+
+// loadDeck reads a deck in any registered format, a card
+// can appear at most copies times
+func loadDeck(filename string, copies int) (deck, error) {
+	bs, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := detectFileFormat(filename, bs).decode(bs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.validate(copies); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// validate checks the rules every format shares, each card must be known
+// and must appear at most copies times, 1 for a single deck or the
+// number of decks in a shoe
+func (d deck) validate(copies int) error {
+	seen := map[Card][]int{}
+	for i, card := range d {
+		if !card.valid() {
+			return &DeckError{Pos: i, Entry: card.String(), Err: ErrUnknownCard}
+		}
+
+		if len(seen[card]) >= copies {
+			return &DeckError{Pos: i, Entry: card.String(), Err: fmt.Errorf("%w, first seen at %d", ErrDuplicateCard, seen[card][0])}
+		}
+		seen[card] = append(seen[card], i)
+	}
+	return nil
+}
+
+// parseEntry is used by the text based formats to read a single card
+func parseEntry(pos int, entry string) (Card, error) {
+	if entry == "" {
+		return Card{}, &DeckError{Pos: pos, Entry: entry, Err: ErrEmptyEntry}
+	}
+
+	card, err := ParseCard(entry)
+	if err != nil {
+		return Card{}, &DeckError{Pos: pos, Entry: entry, Err: ErrUnknownCard}
+	}
+	return card, nil
+}
+
+// parseFields is used by the formats that store the rank and suit separately
+func parseFields(pos int, rank string, suit string) (Card, error) {
+	entry := rank + " of " + suit
+	if rank == "" || suit == "" {
+		return Card{}, &DeckError{Pos: pos, Entry: entry, Err: ErrEmptyEntry}
+	}
+
+	r, rerr := ParseRank(rank)
+	s, serr := ParseSuit(suit)
+	card := Card{Rank: r, Suit: s}
+	if rerr != nil || serr != nil || !card.valid() {
+		return Card{}, &DeckError{Pos: pos, Entry: entry, Err: ErrUnknownCard}
+	}
+	return card, nil
+}
+
+// commaFormat is the original format, a single line of comma separated cards
+type commaFormat struct{}
+
+func (commaFormat) name() string {
+	return "comma"
+}
+
+func (commaFormat) detect(bs []byte) bool {
+	return true
+}
+
+func (commaFormat) encode(d deck) ([]byte, error) {
+	return []byte(d.toString()), nil
+}
+
+func (commaFormat) decode(bs []byte) (deck, error) {
+	return splitDeckString(string(bs))
+}
+
+// linesFormat writes one card per line
+type linesFormat struct{}
+
+func (linesFormat) name() string {
+	return "lines"
+}
+
+// detect needs a newline between two cards, a single card followed by a newline
+// is a comma file with a trailing newline unless the extension says otherwise
+func (linesFormat) detect(bs []byte) bool {
+	i := bytes.IndexByte(bs, '\n')
+	return i >= 0 && i < len(bs)-1 && bytes.IndexByte(bs[:i], ',') < 0
+}
+
+// singleLine reports whether the bytes are a single card followed by a newline,
+// the only lines file that detect can't tell apart from a comma file
+func (linesFormat) singleLine(bs []byte) bool {
+	return bytes.IndexByte(bs, '\n') == len(bs)-1 && bytes.IndexByte(bs, ',') < 0
+}
+
+func (linesFormat) encode(d deck) ([]byte, error) {
+	var b strings.Builder
+	for _, card := range d {
+		b.WriteString(card.String())
+		b.WriteString("\n")
+	}
+	return []byte(b.String()), nil
+}
+
+func (linesFormat) decode(bs []byte) (deck, error) {
+	cards := deck{}
+	s := strings.TrimSuffix(string(bs), "\n")
+	if s == "" {
+		return cards, nil
+	}
+
+	for i, line := range strings.Split(s, "\n") {
+		card, err := parseEntry(i, strings.TrimSuffix(line, "\r"))
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+// csvFormat writes a header and one rank,suit record per card
+type csvFormat struct{}
+
+var csvHeader = []string{"rank", "suit"}
+
+func (csvFormat) name() string {
+	return "csv"
+}
+
+func (csvFormat) detect(bs []byte) bool {
+	return bytes.HasPrefix(bs, []byte(strings.Join(csvHeader, ",")+"\n"))
+}
+
+func (csvFormat) encode(d deck) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(csvHeader)
+	for _, card := range d {
+		w.Write([]string{card.Rank.String(), card.Suit.String()})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func (csvFormat) decode(bs []byte) (deck, error) {
+	r := csv.NewReader(bytes.NewReader(bs))
+	r.FieldsPerRecord = len(csvHeader)
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	cards := deck{}
+	// the first record is the header
+	for i, record := range records[1:] {
+		card, err := parseFields(i, record[0], record[1])
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+// jsonFormat writes an array of {"rank": ..., "suit": ...} objects
+type jsonFormat struct{}
+
+type jsonCard struct {
+	Rank string `json:"rank"`
+	Suit string `json:"suit"`
+}
+
+func (jsonFormat) name() string {
+	return "json"
+}
+
+func (jsonFormat) detect(bs []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(bs), []byte("["))
+}
+
+func (jsonFormat) encode(d deck) ([]byte, error) {
+	cards := []jsonCard{}
+	for _, card := range d {
+		cards = append(cards, jsonCard{Rank: card.Rank.String(), Suit: card.Suit.String()})
+	}
+	return json.MarshalIndent(cards, "", "  ")
+}
+
+func (jsonFormat) decode(bs []byte) (deck, error) {
+	cards := []jsonCard{}
+	if err := json.Unmarshal(bs, &cards); err != nil {
+		return nil, err
+	}
+
+	d := deck{}
+	for i, c := range cards {
+		card, err := parseFields(i, c.Rank, c.Suit)
+		if err != nil {
+			return nil, err
+		}
+		d = append(d, card)
+	}
+	return d, nil
+}
+
+// binaryFormat writes a short header followed by one byte per card,
+// the suit in the high four bits and the rank in the low four bits
+type binaryFormat struct{}
+
+var binaryMagic = []byte("DCK1")
+
+func (binaryFormat) name() string {
+	return "binary"
+}
+
+func (binaryFormat) detect(bs []byte) bool {
+	return bytes.HasPrefix(bs, binaryMagic)
+}
+
+func (binaryFormat) encode(d deck) ([]byte, error) {
+	bs := append([]byte{}, binaryMagic...)
+	for i, card := range d {
+		if !card.valid() {
+			return nil, &DeckError{Pos: i, Entry: card.String(), Err: ErrUnknownCard}
+		}
+		bs = append(bs, byte(card.Suit)<<4|byte(card.Rank))
+	}
+	return bs, nil
+}
+
+func (binaryFormat) decode(bs []byte) (deck, error) {
+	d := deck{}
+	for i, b := range bs[len(binaryMagic):] {
+		card := Card{Rank: Rank(b & 0x0f), Suit: Suit(b >> 4)}
+		if !card.valid() {
+			return nil, &DeckError{Pos: i, Entry: fmt.Sprintf("0x%02x", b), Err: ErrUnknownCard}
+		}
+		d = append(d, card)
+	}
+	return d, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveAsAndNewDeckFromFileForEveryFormat(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, f := range deckFormats {
+		filename := filepath.Join(dir, "cards_"+f.name())
+
+		d := newDeck()
+		d.shuffleSeed(3)
+		if err := d.saveAs(filename, f.name()); err != nil {
+			t.Fatalf("Expected deck to be saved as %v, but got %v", f.name(), err)
+		}
+
+		loadedDeck, err := newDeckFromFile(filename)
+		if err != nil {
+			t.Fatalf("Expected %v deck to be loaded, but got %v", f.name(), err)
+		}
+
+		if len(loadedDeck) != 52 {
+			t.Errorf("Expected %v deck length of 52, but got %v", f.name(), len(loadedDeck))
+			continue
+		}
+
+		for i := range d {
+			if d[i] != loadedDeck[i] {
+				t.Errorf("Expected %v at position %v of %v deck, but got %v", d[i], i, f.name(), loadedDeck[i])
+				break
+			}
+		}
+	}
+}
+
+func TestEmptyDeckForEveryFormat(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, f := range deckFormats {
+		filename := filepath.Join(dir, "empty_"+f.name())
+		if err := (deck{}).saveAs(filename, f.name()); err != nil {
+			t.Fatalf("Expected empty deck to be saved as %v, but got %v", f.name(), err)
+		}
+
+		loadedDeck, err := newDeckFromFile(filename)
+		if err != nil || len(loadedDeck) != 0 {
+			t.Errorf("Expected empty %v deck, but got %v cards and %v", f.name(), len(loadedDeck), err)
+		}
+	}
+}
+
+func TestSaveToFileUsesExtension(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"cards.json": "json",
+		"cards.csv":  "csv",
+		"cards.txt":  "lines",
+		"cards.bin":  "binary",
+		"cards":      "comma",
+	}
+
+	for name, format := range tests {
+		filename := filepath.Join(dir, name)
+		if err := newDeck().saveToFile(filename); err != nil {
+			t.Fatalf("Expected deck to be saved, but got %v", err)
+		}
+
+		bs, _ := ioutil.ReadFile(filename)
+		if detected := detectFormat(bs).name(); detected != format {
+			t.Errorf("Expected %v to be saved as %v, but got %v", name, format, detected)
+		}
+	}
+}
+
+func TestBinaryFormatIsOneBytePerCard(t *testing.T) {
+	bs, err := binaryFormat{}.encode(newDeck())
+	if err != nil {
+		t.Fatalf("Expected deck to be encoded, but got %v", err)
+	}
+
+	if len(bs) != len(binaryMagic)+52 {
+		t.Errorf("Expected %v bytes, but got %v", len(binaryMagic)+52, len(bs))
+	}
+}
+
+func TestNewDeckFromFileReadsLegacyFile(t *testing.T) {
+	d, err := newDeckFromFile("my_cards")
+	if err != nil {
+		t.Fatalf("Expected my_cards to be loaded, but got %v", err)
+	}
+
+	if len(d) != 52 {
+		t.Errorf("Expected deck length of 52, but got %v", len(d))
+	}
+}
+
+func TestSingleCardWithTrailingNewline(t *testing.T) {
+	dir := t.TempDir()
+
+	// a comma file can't end with a newline, even with a single card
+	filename := filepath.Join(dir, "cards")
+	if err := ioutil.WriteFile(filename, []byte("Ace of Spades\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newDeckFromFile(filename); !errors.Is(err, ErrTrailingNewline) {
+		t.Errorf("Expected %v, but got %v", ErrTrailingNewline, err)
+	}
+
+	// the same card saved in the lines format is read back
+	filename = filepath.Join(dir, "cards.txt")
+	if err := (deck{{Rank: Ace, Suit: Spades}}).saveToFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	d, err := newDeckFromFile(filename)
+	if err != nil || len(d) != 1 || d[0] != (Card{Rank: Ace, Suit: Spades}) {
+		t.Errorf("Expected the ace of spades, but got %v and %v", d, err)
+	}
+}
@@ -1,5 +1,10 @@
 package main
 
+import (
+	"fmt"
+	"os"
+)
+
 func main() {
 	// var card string = "Ace of Spades"
 	// same thing as above but with type inference
@@ -7,7 +12,7 @@ func main() {
 	// card := newCard()
 
 	// cards := newDeck()
-	// hand, remainingCards := deal(cards, 5)
+	// hand, remainingCards, err := deal(cards, 5)
 	// hand.print()
 	// remainingCards.print()
 	// fmt.Println(cards.toString())
@@ -15,10 +20,29 @@ func main() {
 	// cards := newDeck()
 	// cards.saveToFile("my_cards")
 
-	// cards := newDeckFromFile("my_cards")
+	// cards, err := newDeckFromFile("my_cards")
 	// cards.print()
 
-	cards := newDeck()
-	cards.shuffle()
-	cards.print()
+	// cards := newDeck()
+	// cards.shuffle()
+	// cards.print()
+
+	// an interactive session, commands are read one per line
+	// so a script can be piped into it, try help
+	s := newSession(os.Stdout, cardStyle{}, nil)
+	if isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		s.style = terminalStyle()
+		fmt.Println("cards, type help for the commands")
+		err := s.runTerminal(os.Stdin)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := s.runScript(os.Stdin); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// handCategory is the kind of a poker hand, from the weakest to the strongest
+type handCategory int
+
+const (
+	highCard handCategory = iota
+	onePair
+	twoPair
+	threeOfAKind
+	straight
+	flush
+	fullHouse
+	fourOfAKind
+	straightFlush
+	royalFlush
+)
+
+var categoryNames = []string{
+	"High Card",
+	"One Pair",
+	"Two Pair",
+	"Three of a Kind",
+	"Straight",
+	"Flush",
+	"Full House",
+	"Four of a Kind",
+	"Straight Flush",
+	"Royal Flush",
+}
+
+func (c handCategory) String() string {
+	if c < 0 || int(c) >= len(categoryNames) {
+		return fmt.Sprintf("handCategory(%d)", int(c))
+	}
+	return categoryNames[c]
+}
+
+// handValue orders poker hands, a higher value is a better hand and
+// equal values split the pot.
+// The category is stored above bit 20 and the five ranks that break
+// ties are stored below it, four bits each, most important first.
+type handValue uint32
+
+func (v handValue) category() handCategory {
+	return handCategory(v >> 20)
+}
+
+func (v handValue) String() string {
+	return v.category().String()
+}
+
+// ranks are stored as bit indexes, 0 for a Two up to 12 for an Ace
+const rankBits = 13
+
+// lookup tables indexed by a mask of rank bits, built once in init
+var (
+	// the index of the highest card of the best straight plus one, 0 if there is none
+	straightTable [1 << rankBits]uint8
+	// the five highest ranks of the mask packed like the kickers of a handValue
+	kickerTable [1 << rankBits]uint32
+)
+
+func init() {
+	// the wheel, A-2-3-4-5, is the lowest straight and its high card is the Five
+	wheel := uint16(1<<12 | 0xf)
+
+	for mask := 0; mask < 1<<rankBits; mask++ {
+		m := uint16(mask)
+		for high := 12; high >= 4; high-- {
+			run := uint16(0x1f) << (high - 4)
+			if m&run == run {
+				straightTable[mask] = uint8(high + 1)
+				break
+			}
+		}
+		if straightTable[mask] == 0 && m&wheel == wheel {
+			straightTable[mask] = 3 + 1
+		}
+
+		kickerTable[mask] = packRanks(m, 5)
+	}
+}
+
+// packRanks packs the n highest ranks of the mask, the first in the highest bits
+func packRanks(mask uint16, n int) uint32 {
+	packed := uint32(0)
+	for i := 0; i < 5; i++ {
+		packed <<= 4
+		if i < n && mask != 0 {
+			high := bits.Len16(mask) - 1
+			packed |= uint32(high)
+			mask &^= 1 << high
+		}
+	}
+	return packed
+}
+
+func makeValue(c handCategory, kickers uint32) handValue {
+	return handValue(uint32(c)<<20 | kickers)
+}
+
+// evaluate ranks the best 5 card hand that can be made from 5 to 7 cards
+// without building the combinations, so it can run millions of times per second.
+// It doesn't check the cards, jokers and invalid cards are skipped,
+// use checkPokerHand first when the cards come from the user
+func evaluate(cards deck) handValue {
+	var counts [rankBits]uint8
+	var suits [5]uint16
+	all := uint16(0)
+
+	for _, c := range cards {
+		idx := c.Rank.High() - 2
+		if idx < 0 || idx >= rankBits || c.Suit < Spades || c.Suit > Clubs {
+			continue
+		}
+		counts[idx]++
+		suits[c.Suit] |= 1 << idx
+		all |= 1 << idx
+	}
+
+	flushMask := uint16(0)
+	for _, m := range suits {
+		if bits.OnesCount16(m) >= 5 {
+			flushMask = m
+		}
+	}
+
+	if flushMask != 0 {
+		if high := straightTable[flushMask]; high != 0 {
+			if high == 13 {
+				return makeValue(royalFlush, uint32(high-1)<<16)
+			}
+			return makeValue(straightFlush, uint32(high-1)<<16)
+		}
+	}
+
+	// the masks of the ranks that appear at least 2, 3 and 4 times
+	var pairs, trips, quads uint16
+	for idx, n := range counts {
+		if n >= 2 {
+			pairs |= 1 << idx
+		}
+		if n >= 3 {
+			trips |= 1 << idx
+		}
+		if n >= 4 {
+			quads |= 1 << idx
+		}
+	}
+
+	if quads != 0 {
+		q := highest(quads)
+		return makeValue(fourOfAKind, uint32(q)<<16|packRanks(all&^(1<<q), 1)>>4)
+	}
+
+	if trips != 0 {
+		t := highest(trips)
+		if rest := pairs &^ (1 << t); rest != 0 {
+			return makeValue(fullHouse, uint32(t)<<16|uint32(highest(rest))<<12)
+		}
+	}
+
+	if flushMask != 0 {
+		return makeValue(flush, kickerTable[flushMask])
+	}
+
+	if high := straightTable[all]; high != 0 {
+		return makeValue(straight, uint32(high-1)<<16)
+	}
+
+	if trips != 0 {
+		t := highest(trips)
+		return makeValue(threeOfAKind, uint32(t)<<16|packRanks(all&^(1<<t), 2)>>4)
+	}
+
+	if bits.OnesCount16(pairs) >= 2 {
+		p1 := highest(pairs)
+		p2 := highest(pairs &^ (1 << p1))
+		rest := all &^ (1<<p1 | 1<<p2)
+		return makeValue(twoPair, uint32(p1)<<16|uint32(p2)<<12|packRanks(rest, 1)>>8)
+	}
+
+	if pairs != 0 {
+		p := highest(pairs)
+		return makeValue(onePair, uint32(p)<<16|packRanks(all&^(1<<p), 3)>>4)
+	}
+
+	return makeValue(highCard, kickerTable[all])
+}
+
+func highest(mask uint16) int {
+	return bits.Len16(mask) - 1
+}
+
+// pokerCard reports whether the card can be in a poker hand, jokers can't
+func pokerCard(c Card) bool {
+	return c.valid() && !c.IsJoker()
+}
+
+// checkPokerHand returns ErrUnknownCard for the first card that can't be in a poker hand
+// and ErrDuplicateCard for the first card that is in the hand twice
+func checkPokerHand(cards deck) error {
+	seen := map[Card]bool{}
+	for _, c := range cards {
+		if !pokerCard(c) {
+			return fmt.Errorf("%w: %v", ErrUnknownCard, c)
+		}
+		if seen[c] {
+			return fmt.Errorf("%w: %v", ErrDuplicateCard, c)
+		}
+		seen[c] = true
+	}
+	return nil
+}
+
+// compareHands returns 1 when a wins, -1 when b wins and 0 for a tie
+func compareHands(a deck, b deck) (int, error) {
+	if err := checkPokerHand(a); err != nil {
+		return 0, err
+	}
+	if err := checkPokerHand(b); err != nil {
+		return 0, err
+	}
+
+	va, vb := evaluate(a), evaluate(b)
+	switch {
+	case va > vb:
+		return 1, nil
+	case va < vb:
+		return -1, nil
+	}
+	return 0, nil
+}
+
+// bestFive returns the five cards that make the best hand, for example
+// from the 2 hole cards and 5 board cards of Texas Hold'em
+func bestFive(cards deck) (deck, handValue, error) {
+	if err := checkPokerHand(cards); err != nil {
+		return nil, 0, err
+	}
+	if len(cards) < 5 {
+		return nil, 0, fmt.Errorf("%w: a hand needs 5 cards, got %d", ErrNotEnoughCards, len(cards))
+	}
+	if len(cards) == 5 {
+		return cards, evaluate(cards), nil
+	}
+
+	var best deck
+	bestValue := handValue(0)
+	hand := make(deck, 5)
+	forEachCombination(len(cards), 5, func(idx []int) {
+		for i, j := range idx {
+			hand[i] = cards[j]
+		}
+		if v := evaluate(hand); best == nil || v > bestValue {
+			best = append(deck{}, hand...)
+			bestValue = v
+		}
+	})
+	return best, bestValue, nil
+}
+
+// forEachCombination calls fn with every set of k indexes out of n in
+// lexicographic order, fn must not keep the slice
+func forEachCombination(n int, k int, fn func(idx []int)) {
+	if k > n || k < 0 {
+		return
+	}
+
+	idx := make([]int, k)
+	for i := range idx {
+		idx[i] = i
+	}
+
+	for {
+		fn(idx)
+
+		i := k - 1
+		for i >= 0 && idx[i] == n-k+i {
+			i--
+		}
+		if i < 0 {
+			return
+		}
+
+		idx[i]++
+		for j := i + 1; j < k; j++ {
+			idx[j] = idx[j-1] + 1
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func mustParse(t testing.TB, s string) deck {
+	d, err := parseShortCards(s)
+	if err != nil {
+		t.Fatalf("Expected %q to parse, but got %v", s, err)
+	}
+	return d
+}
+
+func TestShortCards(t *testing.T) {
+	for _, c := range append(newDeck(), BlackJoker, RedJoker) {
+		parsed, err := ParseShortCard(c.Short())
+		if err != nil || parsed != c {
+			t.Errorf("Expected %v to round trip, but got %v and %v", c, parsed, err)
+		}
+	}
+
+	if _, err := ParseShortCard("1s"); err == nil {
+		t.Errorf("Expected an error parsing 1s")
+	}
+}
+
+func TestEvaluateCategories(t *testing.T) {
+	tests := []struct {
+		hand     string
+		category handCategory
+	}{
+		{"As Ks Qs Js Ts", royalFlush},
+		{"9h Kh Qh Jh Th", straightFlush},
+		{"Ad 2d 3d 4d 5d", straightFlush},
+		{"7c 7d 7h 7s 2c", fourOfAKind},
+		{"Kc Kd Kh 2s 2c", fullHouse},
+		{"2h 9h Jh 4h 6h", flush},
+		{"Tc Jd Qh Ks As", straight},
+		{"Ac 2d 3h 4s 5c", straight},
+		{"8c 8d 8h Ks 2c", threeOfAKind},
+		{"8c 8d 3h 3s 2c", twoPair},
+		{"Ac Ad 9h 3s 2c", onePair},
+		{"Ac Qd 9h 3s 2c", highCard},
+		// seven cards, the best five are picked
+		{"As Ks Qs Js Ts 9s 8s", royalFlush},
+		{"2c 3c 4c 5c 6c 7d 7h", straightFlush},
+		{"Kc Kd Kh Qs Qc Qd 2h", fullHouse},
+		{"7c 7d 7h 7s Kc Kd Kh", fourOfAKind},
+		{"2h 9h Jh 4h 6h 7s 8c", flush},
+		{"Ac 2d 3h 4s 5c 6d Kh", straight},
+		{"8c 8d 3h 3s 2c 2d Ah", twoPair},
+	}
+
+	for _, test := range tests {
+		d := mustParse(t, test.hand)
+		if got := evaluate(d).category(); got != test.category {
+			t.Errorf("Expected %v for %v, but got %v", test.category, test.hand, got)
+		}
+
+		best, v, err := bestFive(d)
+		if err != nil || len(best) != 5 || v != evaluate(d) {
+			t.Errorf("Expected bestFive to agree with evaluate for %v, but got %v", test.hand, best)
+		}
+	}
+}
+
+func TestCompareHands(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		result int
+	}{
+		{"As Ks Qs Js Ts", "9h Kh Qh Jh Th", 1},
+		// the wheel is the lowest straight
+		{"Ac 2d 3h 4s 5c", "2c 3d 4h 5s 6c", -1},
+		{"7c 7d 7h 7s Ac", "7c 7d 7h 7s Kc", 1},
+		{"Kc Kd Kh 2s 2c", "Qc Qd Qh As Ac", 1},
+		{"Kc Kd Kh 3s 3c", "Kc Kd Kh 2s 2c", 1},
+		{"Ah 9h Jh 4h 6h", "Ad 9d Jd 4d 5d", 1},
+		{"8c 8d 8h Ks 2c", "8c 8d 8h Qs Jc", 1},
+		{"8c 8d 3h 3s Ac", "8c 8d 3h 3s Kc", 1},
+		{"8c 8d 4h 4s 2c", "8c 8d 3h 3s Kc", 1},
+		{"Ac Ad 9h 4s 2c", "Ac Ad 9h 3s 2c", 1},
+		{"Ac Qd 9h 3s 2c", "Ac Qd 9h 3s 2h", 0},
+		{"Tc Jd Qh Ks Ac", "Td Jh Qs Kc Ad", 0},
+		// the third pair only counts as a kicker
+		{"Ac Ad Kh Ks Qc Qd 2h", "Ac Ad Kh Ks Qc Jd 2h", 0},
+		{"Ac Ad Kh Ks 2c 2d Jh", "Ac Ad Kh Ks Qc 2d 3h", -1},
+		// the board plays for both players
+		{"2c 3d As Ks Qs Js Ts", "4c 5d As Ks Qs Js Ts", 0},
+	}
+
+	for _, test := range tests {
+		if got, err := compareHands(mustParse(t, test.a), mustParse(t, test.b)); err != nil || got != test.result {
+			t.Errorf("Expected %v comparing %v and %v, but got %v", test.result, test.a, test.b, got)
+		}
+	}
+}
+
+func TestPokerRejectsBadHands(t *testing.T) {
+	hand := append(mustParse(t, "As Ks Qs Js 2c 3d"), RedJoker)
+
+	if _, _, err := bestFive(hand); !errors.Is(err, ErrUnknownCard) {
+		t.Errorf("Expected %v for a joker, but got %v", ErrUnknownCard, err)
+	}
+	if _, err := compareHands(mustParse(t, "As Ks Qs Js Ts"), deck{BlackJoker, {}, {}, {}, {}}); !errors.Is(err, ErrUnknownCard) {
+		t.Errorf("Expected %v for a joker and zero cards, but got %v", ErrUnknownCard, err)
+	}
+
+	if _, _, err := bestFive(mustParse(t, "As Ks Qs Js")); !errors.Is(err, ErrNotEnoughCards) {
+		t.Errorf("Expected %v for 4 cards, but got %v", ErrNotEnoughCards, err)
+	}
+	if _, _, err := bestFive(mustParse(t, "As Ks Qs Js As")); !errors.Is(err, ErrDuplicateCard) {
+		t.Errorf("Expected %v for a card dealt twice, but got %v", ErrDuplicateCard, err)
+	}
+	if _, err := compareHands(mustParse(t, "As Ks Qs Js Js"), mustParse(t, "As Ks Qs Js Ts")); !errors.Is(err, ErrDuplicateCard) {
+		t.Errorf("Expected %v for a card dealt twice, but got %v", ErrDuplicateCard, err)
+	}
+
+	// evaluate doesn't check, the joker is skipped
+	if got := evaluate(hand).category(); got != highCard {
+		t.Errorf("Expected the joker to be skipped, but got %v", got)
+	}
+}
+
+// the number of hands of each category out of all the 2,598,960 five card hands
+func TestEvaluateAllFiveCardHands(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the full enumeration in short mode")
+	}
+
+	expected := map[handCategory]int{
+		royalFlush:    4,
+		straightFlush: 36,
+		fourOfAKind:   624,
+		fullHouse:     3744,
+		flush:         5108,
+		straight:      10200,
+		threeOfAKind:  54912,
+		twoPair:       123552,
+		onePair:       1098240,
+		highCard:      1302540,
+	}
+
+	d := newDeck()
+	counts := map[handCategory]int{}
+	hand := make(deck, 5)
+	forEachCombination(len(d), 5, func(idx []int) {
+		for i, j := range idx {
+			hand[i] = d[j]
+		}
+		counts[evaluate(hand).category()]++
+	})
+
+	for category, n := range expected {
+		if counts[category] != n {
+			t.Errorf("Expected %v hands of %v, but got %v", n, category, counts[category])
+		}
+	}
+}
+
+// every 7 card hand must agree with the brute force over its combinations
+func TestEvaluateMatchesBestFive(t *testing.T) {
+	r := rand.New(rand.NewSource(9))
+	for i := 0; i < 2000; i++ {
+		d := newDeck()
+		d.shuffleWith(r)
+		hand := d[:7]
+
+		best, v, _ := bestFive(hand)
+		if evaluate(best) != v || evaluate(hand) != v {
+			t.Fatalf("Expected %v to evaluate as %v, but got %v", hand, v, evaluate(hand))
+		}
+	}
+}
+
+func BenchmarkEvaluate7(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	hands := []deck{}
+	for i := 0; i < 1000; i++ {
+		d := newDeck()
+		d.shuffleWith(r)
+		hands = append(hands, d[:7])
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		evaluate(hands[i%len(hands)])
+	}
+}
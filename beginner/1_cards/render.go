@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// cardStyle decides how cards are printed, terminals get suit glyphs
+// and colors while scripts get the plain names
+type cardStyle struct {
+	unicode bool
+	color   bool
+}
+
+var suitGlyphs = map[Suit]string{
+	Spades:   "♠",
+	Diamonds: "♦",
+	Hearts:   "♥",
+	Clubs:    "♣",
+}
+
+const (
+	ansiRed   = "\x1b[31m"
+	ansiReset = "\x1b[0m"
+)
+
+// terminalStyle follows the NO_COLOR convention, https://no-color.org
+func terminalStyle() cardStyle {
+	return cardStyle{unicode: true, color: os.Getenv("NO_COLOR") == ""}
+}
+
+func (st cardStyle) card(c Card) string {
+	if !st.unicode {
+		return c.String()
+	}
+
+	name := c.Short()
+	if c.IsJoker() {
+		name = "🃏" + name[1:]
+	} else if glyph, ok := suitGlyphs[c.Suit]; ok {
+		name = name[:1] + glyph
+	}
+
+	if st.color && c.IsRed() {
+		return ansiRed + name + ansiReset
+	}
+	return name
+}
+
+// render prints glyphs on a single line and plain names one per line
+func (st cardStyle) render(d deck) string {
+	names := []string{}
+	for _, c := range d {
+		names = append(names, st.card(c))
+	}
+
+	if st.unicode {
+		return strings.Join(names, " ")
+	}
+	return strings.Join(names, "\n")
+}
+
+// renderHand numbers the cards so they can be discarded by position
+func (st cardStyle) renderHand(d deck) string {
+	names := []string{}
+	for i, c := range d {
+		names = append(names, fmt.Sprintf("%d %v", i+1, st.card(c)))
+	}
+
+	if st.unicode {
+		return strings.Join(names, "  ")
+	}
+	return strings.Join(names, "\n")
+}
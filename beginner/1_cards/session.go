@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxShoeDecks is the largest shoe load accepts, casino shoes have up to 8 decks
+// so a loaded file may hold up to 8 copies of each card
+const maxShoeDecks = 8
+
+// session is the state the interactive commands work on
+type session struct {
+	dealer  *dealer
+	hand    deck
+	history []string
+	undo    []snapshot
+	out     io.Writer
+	style   cardStyle
+	source  rand.Source
+}
+
+// snapshot is a copy of the cards before a command changed them
+type snapshot struct {
+	stock, discards, hand deck
+}
+
+// command is a single REPL command, commands that change the cards
+// save a snapshot first so they can be undone
+type command struct {
+	usage   string
+	help    string
+	mutates bool
+	run     func(s *session, args []string) error
+}
+
+var commands map[string]command
+
+// deck variants understood by the new command
+var variants = map[string]func() *deckBuilder{
+	"standard": newDeckBuilder,
+	"jokers":   func() *deckBuilder { return newDeckBuilder().withJokers(2) },
+	"piquet":   newPiquetBuilder,
+	"euchre":   newEuchreBuilder,
+	"spanish":  newSpanishBuilder,
+	"shoe":     func() *deckBuilder { return newShoeBuilder(6) },
+}
+
+var errQuit = errors.New("quit")
+
+func init() {
+	// assigned in init because the help command refers to commands
+	commands = map[string]command{
+		"new":     {"new [variant]", "start over with a new deck, variants: " + strings.Join(variantNames(), ", "), true, (*session).cmdNew},
+		"shuffle": {"shuffle [seed]", "shuffle the stock, a seed makes it reproducible", true, (*session).cmdShuffle},
+		"deal":    {"deal <n>", "discard the hand and deal a new hand of n cards", true, (*session).cmdDeal},
+		"hand":    {"hand", "show the hand", false, (*session).cmdHand},
+		"draw":    {"draw [n]", "draw n cards into the hand, 1 by default", true, (*session).cmdDraw},
+		"discard": {"discard [card...]", "discard cards by position or short name, or the whole hand", true, (*session).cmdDiscard},
+		"stock":   {"stock", "show the cards left in the stock", false, (*session).cmdStock},
+		"save":    {"save <file>", "save the stock, the extension picks the format", false, (*session).cmdSave},
+		"load":    {"load <file>", "load a stock saved in any format", true, (*session).cmdLoad},
+		"undo":    {"undo", "undo the last command that changed the cards", false, (*session).cmdUndo},
+		"history": {"history", "list the commands of this session", false, (*session).cmdHistory},
+		"help":    {"help", "list the commands", false, (*session).cmdHelp},
+		"quit":    {"quit", "leave the session", false, func(*session, []string) error { return errQuit }},
+	}
+}
+
+func variantNames() []string {
+	names := []string{}
+	for name := range variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func commandNames() []string {
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newSession(out io.Writer, style cardStyle, source rand.Source) *session {
+	if source == nil {
+		source = rand.NewSource(time.Now().UnixNano())
+	}
+	return &session{
+		dealer: newDealer(newDeck(), source),
+		hand:   deck{},
+		out:    out,
+		style:  style,
+		source: source,
+	}
+}
+
+// execute runs a single line, errors are printed and the session goes on,
+// only errQuit is returned
+func (s *session) execute(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	s.history = append(s.history, strings.Join(fields, " "))
+
+	cmd, ok := commands[fields[0]]
+	if !ok {
+		fmt.Fprintf(s.out, "Error: unknown command %q, try help\n", fields[0])
+		return nil
+	}
+
+	before := s.snapshot()
+	err := cmd.run(s, fields[1:])
+	if err == errQuit {
+		return err
+	}
+	if err != nil {
+		fmt.Fprintln(s.out, "Error:", err)
+		return nil
+	}
+
+	if cmd.mutates {
+		s.undo = append(s.undo, before)
+	}
+	return nil
+}
+
+// runScript reads one command per line, it is used when stdin is not a terminal
+func (s *session) runScript(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if err := s.execute(scanner.Text()); err == errQuit {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *session) snapshot() snapshot {
+	return snapshot{
+		stock:    append(deck{}, s.dealer.stock...),
+		discards: append(deck{}, s.dealer.discards...),
+		hand:     append(deck{}, s.hand...),
+	}
+}
+
+func (s *session) restore(snap snapshot) {
+	s.dealer.stock = snap.stock
+	s.dealer.discards = snap.discards
+	s.hand = snap.hand
+}
+
+// complete returns the words that can finish the last word of the line
+func (s *session) complete(line string) []string {
+	fields := strings.Fields(line)
+	last := ""
+	if len(fields) > 0 && !strings.HasSuffix(line, " ") {
+		last = fields[len(fields)-1]
+		fields = fields[:len(fields)-1]
+	}
+
+	candidates := []string{}
+	if len(fields) == 0 {
+		candidates = commandNames()
+	} else {
+		switch fields[0] {
+		case "new":
+			candidates = variantNames()
+		case "discard":
+			for _, c := range s.hand {
+				candidates = append(candidates, c.Short())
+			}
+		case "save", "load":
+			matches, _ := filepath.Glob(last + "*")
+			candidates = matches
+		}
+	}
+
+	result := []string{}
+	for _, c := range candidates {
+		if strings.HasPrefix(c, last) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func parseCount(args []string, fallback int) (int, error) {
+	if len(args) == 0 {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid number of cards %q", args[0])
+	}
+	return n, nil
+}
+
+func (s *session) cmdNew(args []string) error {
+	name := "standard"
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	builder, ok := variants[name]
+	if !ok {
+		return fmt.Errorf("unknown variant %q", name)
+	}
+
+	d, err := builder().build()
+	if err != nil {
+		return err
+	}
+
+	s.dealer = newDealer(d, s.source)
+	s.hand = deck{}
+	fmt.Fprintf(s.out, "new %v deck of %d cards\n", name, len(d))
+	return nil
+}
+
+func (s *session) cmdShuffle(args []string) error {
+	if len(args) > 0 {
+		seed, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid seed %q", args[0])
+		}
+		s.dealer.stock.shuffleSeed(seed)
+	} else {
+		s.dealer.stock.shuffleWith(s.source)
+	}
+	fmt.Fprintf(s.out, "shuffled %d cards\n", len(s.dealer.stock))
+	return nil
+}
+
+func (s *session) cmdDeal(args []string) error {
+	if len(args) == 0 {
+		return errors.New("deal needs the number of cards")
+	}
+	n, err := parseCount(args, 0)
+	if err != nil {
+		return err
+	}
+
+	cards, err := s.dealer.draw(n)
+	if err != nil {
+		return err
+	}
+	s.dealer.discard(s.hand...)
+	s.hand = cards
+	return s.cmdHand(nil)
+}
+
+func (s *session) cmdHand([]string) error {
+	if len(s.hand) == 0 {
+		fmt.Fprintln(s.out, "the hand is empty")
+		return nil
+	}
+	fmt.Fprintln(s.out, s.style.renderHand(s.hand))
+	return nil
+}
+
+func (s *session) cmdDraw(args []string) error {
+	n, err := parseCount(args, 1)
+	if err != nil {
+		return err
+	}
+
+	cards, err := s.dealer.draw(n)
+	if err != nil {
+		return err
+	}
+	s.hand = append(s.hand, cards...)
+	return s.cmdHand(nil)
+}
+
+// cmdDiscard accepts positions starting at 1 or short names like As
+func (s *session) cmdDiscard(args []string) error {
+	if len(args) == 0 {
+		s.dealer.discard(s.hand...)
+		fmt.Fprintf(s.out, "discarded %d cards\n", len(s.hand))
+		s.hand = deck{}
+		return nil
+	}
+
+	remove := map[int]bool{}
+	for _, arg := range args {
+		i, err := s.handIndex(arg)
+		if err != nil {
+			return err
+		}
+		remove[i] = true
+	}
+
+	kept := deck{}
+	for i, c := range s.hand {
+		if remove[i] {
+			s.dealer.discard(c)
+		} else {
+			kept = append(kept, c)
+		}
+	}
+	s.hand = kept
+	return s.cmdHand(nil)
+}
+
+func (s *session) handIndex(arg string) (int, error) {
+	if n, err := strconv.Atoi(arg); err == nil {
+		if n < 1 || n > len(s.hand) {
+			return 0, fmt.Errorf("there is no card %d in the hand", n)
+		}
+		return n - 1, nil
+	}
+
+	c, err := ParseShortCard(arg)
+	if err != nil {
+		return 0, err
+	}
+	for i, h := range s.hand {
+		if h == c {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%v is not in the hand", c)
+}
+
+func (s *session) cmdStock([]string) error {
+	fmt.Fprintf(s.out, "%d cards in the stock, %d discarded\n", len(s.dealer.stock), len(s.dealer.discards))
+	fmt.Fprintln(s.out, s.style.render(s.dealer.stock))
+	return nil
+}
+
+func (s *session) cmdSave(args []string) error {
+	if len(args) != 1 {
+		return errors.New("save needs a file name")
+	}
+	if err := s.dealer.stock.saveToFile(args[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "saved %d cards to %v\n", len(s.dealer.stock), args[0])
+	return nil
+}
+
+func (s *session) cmdLoad(args []string) error {
+	if len(args) != 1 {
+		return errors.New("load needs a file name")
+	}
+
+	// a shoe may hold several copies of each card
+	d, err := newShoeFromFile(args[0], maxShoeDecks)
+	if err != nil {
+		return err
+	}
+	s.dealer = newDealer(d, s.source)
+	s.hand = deck{}
+	fmt.Fprintf(s.out, "loaded %d cards from %v\n", len(d), args[0])
+	return nil
+}
+
+func (s *session) cmdUndo([]string) error {
+	if len(s.undo) == 0 {
+		return errors.New("nothing to undo")
+	}
+	s.restore(s.undo[len(s.undo)-1])
+	s.undo = s.undo[:len(s.undo)-1]
+	fmt.Fprintln(s.out, "undone")
+	return nil
+}
+
+func (s *session) cmdHistory([]string) error {
+	for i, line := range s.history {
+		fmt.Fprintf(s.out, "%4d  %v\n", i+1, line)
+	}
+	return nil
+}
+
+func (s *session) cmdHelp([]string) error {
+	for _, name := range commandNames() {
+		fmt.Fprintf(s.out, "  %-18v %v\n", commands[name].usage, commands[name].help)
+	}
+	return nil
+}
+
+// isTerminal reports whether the file is a character device like a TTY
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
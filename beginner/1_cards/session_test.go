@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runLines(t *testing.T, lines ...string) (*session, string) {
+	var out bytes.Buffer
+	s := newSession(&out, cardStyle{}, rand.NewSource(1))
+	if err := s.runScript(strings.NewReader(strings.Join(lines, "\n"))); err != nil {
+		t.Fatalf("Expected the script to run, but got %v", err)
+	}
+	return s, out.String()
+}
+
+func TestSessionScript(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "stock.json")
+	s, out := runLines(t,
+		"new",
+		"deal 5",
+		"draw 2",
+		"discard 1 As",
+		"save "+filename,
+		"load "+filename,
+		"hand",
+	)
+
+	for _, expected := range []string{
+		"new standard deck of 52 cards",
+		"1 Ace of Spades\n2 Two of Spades",
+		"7 Seven of Spades",
+		"saved 45 cards to " + filename,
+		"loaded 45 cards from " + filename,
+		"the hand is empty",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected the output to contain %q, but got:\n%v", expected, out)
+		}
+	}
+
+	if len(s.history) != 7 {
+		t.Errorf("Expected 7 commands in the history, but got %v", len(s.history))
+	}
+}
+
+func TestSessionUndo(t *testing.T) {
+	s, out := runLines(t, "shuffle 3", "deal 5", "discard 2", "undo", "undo", "hand")
+
+	if len(s.hand) != 0 || len(s.dealer.stock) != 52 {
+		t.Errorf("Expected the deal to be undone, but got %v in hand and %v in stock", len(s.hand), len(s.dealer.stock))
+	}
+
+	d := newDeck()
+	d.shuffleSeed(3)
+	if s.dealer.stock.toString() != d.toString() {
+		t.Errorf("Expected the shuffle to be kept")
+	}
+
+	_, out = runLines(t, "undo")
+	if !strings.Contains(out, "Error: nothing to undo") {
+		t.Errorf("Expected an error with nothing to undo, but got %v", out)
+	}
+}
+
+func TestSessionErrors(t *testing.T) {
+	s, out := runLines(t, "deal 60", "bogus", "discard 9", "new nope", "quit", "deal 1")
+
+	for _, expected := range []string{
+		"Error: not enough cards",
+		`Error: unknown command "bogus"`,
+		"Error: there is no card 9 in the hand",
+		`Error: unknown variant "nope"`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected the output to contain %q, but got:\n%v", expected, out)
+		}
+	}
+
+	if len(s.hand) != 0 {
+		t.Errorf("Expected the session to stop at quit, but got a hand of %v", len(s.hand))
+	}
+}
+
+func TestSessionComplete(t *testing.T) {
+	s, _ := runLines(t, "deal 2")
+	tests := map[string][]string{
+		"sh":         {"shuffle"},
+		"d":          {"deal", "discard", "draw"},
+		"new p":      {"piquet"},
+		"discard ":   {"As", "2s"},
+		"discard 2":  {"2s"},
+		"unknown x ": {},
+	}
+
+	for line, expected := range tests {
+		got := s.complete(line)
+		if strings.Join(got, " ") != strings.Join(expected, " ") {
+			t.Errorf("Expected %v for %q, but got %v", expected, line, got)
+		}
+	}
+}
+
+func TestCardStyle(t *testing.T) {
+	c := Card{Rank: Queen, Suit: Hearts}
+
+	if got := (cardStyle{}).card(c); got != "Queen of Hearts" {
+		t.Errorf("Expected the plain name, but got %v", got)
+	}
+
+	if got := (cardStyle{unicode: true}).card(c); got != "Q♥" {
+		t.Errorf("Expected Q♥, but got %v", got)
+	}
+
+	if got := (cardStyle{unicode: true, color: true}).card(c); got != ansiRed+"Q♥"+ansiReset {
+		t.Errorf("Expected a red Q♥, but got %q", got)
+	}
+}
+
+func TestLineEditorReadsRunes(t *testing.T) {
+	// backspace removes the é and not only its last byte
+	e := &lineEditor{in: bufio.NewReader(strings.NewReader("♠é\x7f♥\rcœur\x7f\x7f\r")), out: &bytes.Buffer{}}
+
+	for _, expected := range []string{"♠♥", "cœ"} {
+		line, err := e.readLine()
+		if err != nil || line != expected {
+			t.Errorf("Expected %q, but got %q: %v", expected, line, err)
+		}
+	}
+	if _, err := e.readLine(); err != io.EOF {
+		t.Errorf("Expected io.EOF at the end of the input, but got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// chi-squared critical values for a significance level of 0.001
+const (
+	chiSquared23 = 49.728
+	chiSquared51 = 86.661
+)
+
+func chiSquared(counts []int, expected float64) float64 {
+	sum := 0.0
+	for _, c := range counts {
+		diff := float64(c) - expected
+		sum += diff * diff / expected
+	}
+	return sum
+}
+
+func TestShuffleKeepsEveryCard(t *testing.T) {
+	d := newDeck()
+	d.shuffleSeed(42)
+
+	seen := map[Card]bool{}
+	for _, c := range d {
+		seen[c] = true
+	}
+
+	if len(d) != 52 || len(seen) != 52 {
+		t.Errorf("Expected 52 distinct cards, but got %v of %v", len(seen), len(d))
+	}
+}
+
+func TestShuffleSeedIsReproducible(t *testing.T) {
+	a := newDeck()
+	b := newDeck()
+	a.shuffleSeed(7)
+	b.shuffleWith(rand.NewSource(7))
+
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("Expected the same card at position %v, but got %v and %v", i, a[i], b[i])
+		}
+	}
+
+	c := newDeck()
+	c.shuffleSeed(8)
+	if a.toString() == c.toString() {
+		t.Errorf("Expected different seeds to give different orders")
+	}
+}
+
+func TestShuffleSmallDecks(t *testing.T) {
+	empty := deck{}
+	empty.shuffleSeed(1)
+
+	single := deck{{Rank: Ace, Suit: Spades}}
+	single.shuffleSeed(1)
+	if single[0] != (Card{Rank: Ace, Suit: Spades}) {
+		t.Errorf("Expected a single card deck to be unchanged, but got %v", single[0])
+	}
+}
+
+// all the 24 orders of a 4 card deck must be equally likely
+func TestShufflePermutationsAreUniform(t *testing.T) {
+	runs := 240000
+	r := rand.New(rand.NewSource(1))
+	counts := map[string]int{}
+
+	for i := 0; i < runs; i++ {
+		d := newDeck()[:4]
+		d.shuffleWith(r)
+		counts[d.toString()]++
+	}
+
+	if len(counts) != 24 {
+		t.Fatalf("Expected 24 permutations, but got %v", len(counts))
+	}
+
+	values := []int{}
+	for _, c := range counts {
+		values = append(values, c)
+	}
+
+	if x := chiSquared(values, float64(runs)/24); x > chiSquared23 {
+		t.Errorf("Expected permutations to be uniform, but chi-squared is %.2f", x)
+	}
+}
+
+// every card must be able to end up in every position, this catches
+// the old shuffle which could never swap a card into the last position
+func TestShufflePositionsAreUniform(t *testing.T) {
+	runs := 52000
+	r := rand.New(rand.NewSource(2))
+	first := make([]int, 52)
+	last := make([]int, 52)
+	index := map[Card]int{}
+	for i, c := range newDeck() {
+		index[c] = i
+	}
+
+	for i := 0; i < runs; i++ {
+		d := newDeck()
+		d.shuffleWith(r)
+		first[index[d[0]]]++
+		for pos, c := range d {
+			if index[c] == 51 {
+				last[pos]++
+			}
+		}
+	}
+
+	if x := chiSquared(first, float64(runs)/52); x > chiSquared51 {
+		t.Errorf("Expected the first position to be uniform, but chi-squared is %.2f", x)
+	}
+
+	if x := chiSquared(last, float64(runs)/52); x > chiSquared51 {
+		t.Errorf("Expected the last card to be uniform over positions, but chi-squared is %.2f", x)
+	}
+}
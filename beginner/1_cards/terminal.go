@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode/utf8"
+)
+
+// key codes read from a terminal without line buffering
+const (
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyBackspace = 8
+	keyTab       = 9
+	keyEnter     = '\r'
+	keyNewline   = '\n'
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// rawMode turns off line buffering and echo with stty, so we can handle
+// tab ourselves without a dependency, it returns a func to restore the terminal
+func rawMode(tty *os.File) (func(), error) {
+	stty := func(args ...string) (string, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = tty
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+
+	state, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := stty("-icanon", "-echo", "-isig", "min", "1"); err != nil {
+		return nil, err
+	}
+	return func() { stty(state) }, nil
+}
+
+// lineEditor reads lines from a raw terminal with history and tab completion
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	prompt   string
+	complete func(line string) []string
+	history  []string
+}
+
+func (e *lineEditor) redraw(line string) {
+	// go back to the start of the line and clear it
+	fmt.Fprintf(e.out, "\r\x1b[K%v%v", e.prompt, line)
+}
+
+// readLine returns io.EOF when ctrl-d is pressed on an empty line
+func (e *lineEditor) readLine() (string, error) {
+	line := ""
+	pos := len(e.history)
+	e.redraw(line)
+
+	for {
+		// runes and not bytes, so names typed with accents stay whole
+		ch, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch ch {
+		case keyEnter, keyNewline:
+			fmt.Fprintln(e.out)
+			if strings.TrimSpace(line) != "" {
+				e.history = append(e.history, line)
+			}
+			return line, nil
+		case keyCtrlC:
+			fmt.Fprintln(e.out, "^C")
+			line = ""
+		case keyCtrlD:
+			if line == "" {
+				fmt.Fprintln(e.out)
+				return "", io.EOF
+			}
+		case keyBackspace, keyDelete:
+			_, size := utf8.DecodeLastRuneInString(line)
+			line = line[:len(line)-size]
+		case keyTab:
+			line = e.tab(line)
+		case keyEscape:
+			// arrow keys are sent as ESC [ A to ESC [ D
+			if next, _ := e.in.ReadByte(); next != '[' {
+				break
+			}
+			switch arrow, _ := e.in.ReadByte(); arrow {
+			case 'A':
+				if pos > 0 {
+					pos--
+					line = e.history[pos]
+				}
+			case 'B':
+				if pos < len(e.history)-1 {
+					pos++
+					line = e.history[pos]
+				} else {
+					pos = len(e.history)
+					line = ""
+				}
+			}
+		default:
+			if ch >= ' ' && ch != utf8.RuneError {
+				line += string(ch)
+			}
+		}
+		e.redraw(line)
+	}
+}
+
+// tab completes the last word, or lists the candidates when there are many
+func (e *lineEditor) tab(line string) string {
+	candidates := e.complete(line)
+	if len(candidates) == 0 {
+		return line
+	}
+
+	start := strings.LastIndex(line, " ") + 1
+	if len(candidates) == 1 {
+		return line[:start] + candidates[0] + " "
+	}
+
+	fmt.Fprintln(e.out)
+	fmt.Fprintln(e.out, strings.Join(candidates, "  "))
+	return line[:start] + commonPrefix(candidates)
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// runTerminal reads commands from a terminal until quit or ctrl-d
+func (s *session) runTerminal(tty *os.File) error {
+	restore, err := rawMode(tty)
+	if err != nil {
+		// without stty we can still read plain lines
+		return s.runScript(tty)
+	}
+	defer restore()
+
+	e := &lineEditor{in: bufio.NewReader(tty), out: s.out, prompt: "cards> ", complete: s.complete}
+	for {
+		line, err := e.readLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.execute(line); err == errQuit {
+			return nil
+		}
+	}
+}